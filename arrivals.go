@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// hourlyArrivalRates is the expected number of customers arriving in each hour the store
+// is open. customersPerDay is what a little busy day with good weather brings in, it is
+// spread evenly over the opening hours and then every hour is scaled by its busy factor
+// and the whole day by the weather.
+func hourlyArrivalRates(openingHours intRange, busyRanges map[string]busyRange, weather optionFactor,
+	customersPerDay int) map[int]float64 {
+
+	rates := map[int]float64{}
+	perHour := float64(customersPerDay) / float64(openingHours.to-openingHours.from)
+	for hour := openingHours.from; hour < openingHours.to; hour++ {
+		busyFactor := busyRanges["busyRange_"+strconv.Itoa(hour)].busyOptionFactor.factor
+		rates[hour] = perHour * float64(busyFactor) * float64(weather.factor)
+	}
+	return rates
+}
+
+// generateArrivalTimes draws a non-homogeneous Poisson process with a rate that is
+// constant within each hour. The gaps are exponential, and when a gap runs past the end
+// of the hour we restart at the next hour with its own rate, which is fine because the
+// exponential has no memory. The times are seconds since midnight, in order.
+func generateArrivalTimes(stream *rand.Rand, openingHours intRange, rates map[int]float64) []float64 {
+	var arrivals []float64
+
+	for hour := openingHours.from; hour < openingHours.to; hour++ {
+		ratePerSecond := rates[hour] / 3600
+		if ratePerSecond <= 0 {
+			continue
+		}
+		hourEnd := float64((hour + 1) * 3600)
+		at := float64(hour * 3600)
+		for {
+			at += stream.ExpFloat64() / ratePerSecond
+			if at >= hourEnd {
+				break
+			}
+			arrivals = append(arrivals, at)
+		}
+	}
+
+	return arrivals
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// batchKPIs are the numbers a batch adds up over its runs, in the order they are reported.
+var batchKPIs = []struct {
+	code  string
+	label string
+}{
+	{"served", "Customers processed"},
+	{"abandonment", "Abandonment %"},
+	{"meanWait", "Mean wait (min)"},
+	{"p90Wait", "p90 wait (min)"},
+	{"utilisation", "Till utilisation %"},
+	{"profit", "Profit after labour"},
+}
+
+// runKPIs are the KPIs of one run, by store and then by code.
+type runKPIs map[string]map[string]float64
+
+func collectKPIs(sim *simulation) runKPIs {
+	kpis := runKPIs{}
+	for _, kStore := range sortedStoreKeys(sim.stores) {
+		eStore := sim.stores[kStore]
+		stats := collectStatistics(sim, kStore)
+		abandoned := stats.reneged + stats.balked + stats.turnedAway + stats.paymentFailed
+
+		utilisation, tills := 0.0, 0
+		for _, kCheckout := range sortedCheckoutKeys(eStore) {
+			eCheckout := eStore.checkouts[kCheckout]
+			if eCheckout.selfService {
+				continue
+			}
+			start, end := storeWindow(sim, eStore)
+			if staffed := eCheckout.staffedSeconds(start, end); staffed > 0 {
+				utilisation += 100 * eCheckout.busySeconds / staffed
+				tills++
+			}
+		}
+		if tills > 0 {
+			utilisation = utilisation / float64(tills)
+		}
+
+		kpis[kStore] = map[string]float64{
+			"served":      float64(stats.served),
+			"abandonment": 100 * float64(abandoned) / math.Max(1, float64(stats.served+abandoned)),
+			"meanWait":    minutes(stats.wait.mean),
+			"p90Wait":     minutes(stats.wait.p90),
+			"utilisation": utilisation,
+			"profit":      collectEconomics(sim, eStore).profit,
+		}
+	}
+	return kpis
+}
+
+// estimate is the mean of a KPI over the runs with its sample standard deviation and the
+// half width of the 95% confidence interval of the mean.
+type estimate struct {
+	runs      int
+	mean      float64
+	sd        float64
+	halfWidth float64
+}
+
+func estimateMean(values []float64) estimate {
+	e := estimate{runs: len(values)}
+	if e.runs == 0 {
+		return e
+	}
+	for _, v := range values {
+		e.mean += v
+	}
+	e.mean = e.mean / float64(e.runs)
+	if e.runs < 2 {
+		return e
+	}
+	squares := 0.0
+	for _, v := range values {
+		squares += (v - e.mean) * (v - e.mean)
+	}
+	// Sample variance this time, the runs are a sample of all the days there could be.
+	e.sd = math.Sqrt(squares / float64(e.runs-1))
+	e.halfWidth = studentT95(e.runs-1) * e.sd / math.Sqrt(float64(e.runs))
+	return e
+}
+
+// studentT95 is the two-sided 95% critical value of Student's t, for a handful of runs the
+// normal 1.96 would make the interval too narrow.
+func studentT95(degrees int) float64 {
+	table := []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+	switch {
+	case degrees < 1:
+		return math.Inf(1)
+	case degrees <= len(table):
+		return table[degrees-1]
+	case degrees <= 60:
+		return 2.000
+	case degrees <= 120:
+		return 1.980
+	}
+	return 1.960
+}
+
+// estimates are the KPIs of the first runs, by store and by code.
+func estimates(results []runKPIs) map[string]map[string]estimate {
+	all := map[string]map[string]estimate{}
+	for kStore := range results[0] {
+		all[kStore] = map[string]estimate{}
+		for _, kpi := range batchKPIs {
+			values := make([]float64, len(results))
+			for i, result := range results {
+				values[i] = result[kStore][kpi.code]
+			}
+			all[kStore][kpi.code] = estimateMean(values)
+		}
+	}
+	return all
+}
+
+// parsePrecision reads "meanWait=0.5", stop once the 95% confidence interval of the mean
+// wait is at most half a minute wide in every store.
+func parsePrecision(text string) (string, float64, error) {
+	code, widthText, found := strings.Cut(text, "=")
+	code = strings.TrimSpace(code)
+	if !found {
+		return "", 0, fmt.Errorf("%q is not kpi=width, for example meanWait=0.5", text)
+	}
+	for _, kpi := range batchKPIs {
+		if kpi.code == code {
+			width, err := parsePositiveFloat(widthText, math.MaxFloat64)
+			return code, width, err
+		}
+	}
+	var codes []string
+	for _, kpi := range batchKPIs {
+		codes = append(codes, kpi.code)
+	}
+	return "", 0, fmt.Errorf("%q is not a KPI: %s", code, strings.Join(codes, ", "))
+}
+
+// batchJob is one run of the batch, built and run by a worker.
+type batchJob struct {
+	index int
+	sim   *simulation
+}
+
+type batchResult struct {
+	index int
+	kpis  runKPIs
+	err   error
+}
+
+// runBatch runs the day for seed, seed+1, ... on the workers and returns the KPIs of every
+// run in seed order. The days are built one at a time, setting up reads the prompts, and
+// then run side by side. With a precision it stops at the first number of runs (at least
+// minRuns) where the confidence interval is narrow enough, so the result is the same
+// whatever the number of workers.
+func runBatch(ctx context.Context, seed int64, runs, workers, minRuns int, precisionCode string, precisionWidth float64) ([]runKPIs, error) {
+	jobs := make(chan batchJob)
+	results := make(chan batchResult)
+	runCtx, stopRuns := context.WithCancel(ctx)
+	defer stopRuns()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for job := range jobs {
+				err := job.sim.run(runCtx)
+				result := batchResult{index: job.index, err: err}
+				if err == nil {
+					result.kpis = collectKPIs(job.sim)
+				}
+				results <- result
+			}
+		}()
+	}
+
+	// precise tells if the first n runs are enough.
+	precise := func(done []runKPIs) bool {
+		if precisionCode == "" || len(done) < minRuns {
+			return false
+		}
+		for _, byCode := range estimates(done) {
+			if 2*byCode[precisionCode].halfWidth > precisionWidth {
+				return false
+			}
+		}
+		return true
+	}
+
+	byIndex := make([]runKPIs, runs)
+	var done []runKPIs
+	var failed error
+	var ready *simulation
+	next, pending := 0, 0
+	for {
+		finished := failed != nil || precise(done)
+		if finished {
+			// The runs still going are not needed any more.
+			stopRuns()
+			ready = nil
+		} else if ready == nil && next < runs {
+			var err error
+			if ready, err = replaySimulation(seed+int64(next), map[string]string{"simulationMode": "E"}); err != nil {
+				failed = err
+				continue
+			}
+		}
+		if ready == nil && pending == 0 {
+			break
+		}
+
+		// Hand the next day to a worker, or take in a finished one.
+		var send chan batchJob
+		if ready != nil {
+			send = jobs
+		}
+		select {
+		case send <- batchJob{index: next, sim: ready}:
+			ready = nil
+			next++
+			pending++
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				if !finished && failed == nil {
+					failed = result.err
+				}
+				continue
+			}
+			byIndex[result.index] = result.kpis
+			for len(done) < next && byIndex[len(done)] != nil && !precise(done) {
+				done = append(done, byIndex[len(done)])
+			}
+		}
+	}
+	close(jobs)
+
+	if failed != nil {
+		return nil, failed
+	}
+	return done, nil
+}
+
+func batchCommand(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed of the first run, the others follow it (0 picks one from the clock)")
+	runs := fs.Int("runs", 30, "days to run, the most when --precision is given")
+	workers := fs.Int("workers", runtime.NumCPU(), "days run side by side")
+	minRuns := fs.Int("min-runs", 10, "days to run at least before --precision can stop the batch")
+	precisionText := fs.String("precision", "", "stop once the 95% confidence interval of a KPI is this wide, for example meanWait=0.5")
+	scenario := addScenarioFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	interactive = false
+	if err := scenario.apply(fs); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not load config: "+err.Error())
+		return exitConfigError
+	}
+	if *runs < 2 || *workers < 1 || *minRuns < 2 {
+		fmt.Fprintln(os.Stderr, "--runs and --min-runs have to be at least 2, --workers at least 1")
+		return exitConfigError
+	}
+	var precisionCode string
+	var precisionWidth float64
+	if *precisionText != "" {
+		var err error
+		if precisionCode, precisionWidth, err = parsePrecision(*precisionText); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid --precision: "+err.Error())
+			return exitConfigError
+		}
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	fmt.Printf("Seed: %d (run again with --seed %d to get the same days)\n", *seed, *seed)
+
+	// Build the day once for the answers every run is built from.
+	console = io.Discard
+	if _, err := setupSimulation(*seed); err != nil {
+		fmt.Fprintln(os.Stderr, "Configuration error: "+err.Error())
+		return exitConfigError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	results, err := runBatch(ctx, *seed, *runs, *workers, *minRuns, precisionCode, precisionWidth)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Simulation failed: "+err.Error())
+		return exitSimulationError
+	}
+
+	fmt.Printf("Batch: %d runs from seed %d on %d workers\n", len(results), *seed, *workers)
+	if precisionCode != "" {
+		if len(results) < *runs {
+			fmt.Printf("Stopped once the 95%% confidence interval of %s was at most %g wide\n", precisionCode, precisionWidth)
+		} else {
+			fmt.Printf("The 95%% confidence interval of %s is still wider than %g after %d runs\n", precisionCode, precisionWidth, *runs)
+		}
+	}
+	printBatchReport(os.Stdout, estimates(results))
+	return exitOK
+}
+
+// printBatchReport is the mean, standard deviation and 95% confidence interval of every
+// KPI, for every store.
+func printBatchReport(out io.Writer, all map[string]map[string]estimate) {
+	var storeKeys []string
+	for kStore := range all {
+		storeKeys = append(storeKeys, kStore)
+	}
+	sort.Strings(storeKeys)
+	for _, kStore := range storeKeys {
+		fmt.Fprintf(out, "===Store: %s\n", kStore)
+		fmt.Fprintf(out, "%-22s %10s %10s %23s\n", "KPI", "Mean", "SD", "95% CI")
+		for _, kpi := range batchKPIs {
+			e := all[kStore][kpi.code]
+			fmt.Fprintf(out, "%-22s %10.2f %10.2f %23s\n", kpi.label, e.mean, e.sd,
+				fmt.Sprintf("[%.2f, %.2f]", e.mean-e.halfWidth, e.mean+e.halfWidth))
+		}
+	}
+}
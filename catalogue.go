@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// catalogueNone is the catalogue answer for baskets of anonymous products, how it always was.
+const catalogueNone = "NONE"
+
+// catalogueEven is the category weights answer that makes every category as popular.
+const catalogueEven = "EVEN"
+
+// weighingSeconds is the extra time to weigh loose produce on the scales.
+const weighingSeconds = 6
+
+// catalogueColumns are the columns a catalogue file needs, in any order.
+var catalogueColumns = []string{"sku", "name", "category", "price", "scan_difficulty", "weighed", "age_restricted"}
+
+// catalogueItem is one line of the catalogue. scanDifficulty multiplies the scan time, 1
+// is a barcode that scans first time.
+type catalogueItem struct {
+	sku            string
+	name           string
+	category       string
+	price          float64
+	scanDifficulty float64
+	weighed        bool
+	ageRestricted  bool
+}
+
+// catalogue is what the store sells, by category, and how popular every category is.
+type catalogue struct {
+	categories []string
+	items      map[string][]catalogueItem
+	weights    map[string]float64
+}
+
+func parseCatalogueFlag(text string) (bool, error) {
+	switch strings.ToUpper(strings.TrimSpace(text)) {
+	case "Y", "YES", "TRUE", "1":
+		return true, nil
+	case "N", "NO", "FALSE", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not Y or N", text)
+}
+
+// loadCatalogue reads a CSV file with a header line naming the catalogueColumns.
+func loadCatalogue(path string) (*catalogue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	column := map[string]int{}
+	for i, name := range header {
+		column[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range catalogueColumns {
+		if _, ok := column[name]; !ok {
+			return nil, fmt.Errorf("%s: the header has no %q column, it needs %s", path, name, strings.Join(catalogueColumns, ","))
+		}
+	}
+
+	cat := &catalogue{items: map[string][]catalogueItem{}, weights: map[string]float64{}}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		item := catalogueItem{
+			sku:      strings.TrimSpace(record[column["sku"]]),
+			name:     strings.TrimSpace(record[column["name"]]),
+			category: strings.ToLower(strings.TrimSpace(record[column["category"]])),
+		}
+		if item.category == "" {
+			return nil, fmt.Errorf("%s line %d: %s has no category", path, line, item.sku)
+		}
+		if item.price, err = parseFloat(record[column["price"]], 0, 1000000); err != nil {
+			return nil, fmt.Errorf("%s line %d: price: %v", path, line, err)
+		}
+		if item.scanDifficulty, err = parsePositiveFloat(record[column["scan_difficulty"]], 100); err != nil {
+			return nil, fmt.Errorf("%s line %d: scan_difficulty: %v", path, line, err)
+		}
+		if item.weighed, err = parseCatalogueFlag(record[column["weighed"]]); err != nil {
+			return nil, fmt.Errorf("%s line %d: weighed: %v", path, line, err)
+		}
+		if item.ageRestricted, err = parseCatalogueFlag(record[column["age_restricted"]]); err != nil {
+			return nil, fmt.Errorf("%s line %d: age_restricted: %v", path, line, err)
+		}
+
+		if cat.items[item.category] == nil {
+			cat.categories = append(cat.categories, item.category)
+		}
+		cat.items[item.category] = append(cat.items[item.category], item)
+	}
+	if len(cat.categories) == 0 {
+		return nil, fmt.Errorf("%s: the catalogue is empty", path)
+	}
+	sort.Strings(cat.categories)
+	return cat, nil
+}
+
+// setWeights reads "produce=30,dairy=20,..." where the numbers are relative popularity.
+// Categories left out are never bought, EVEN makes them all as popular.
+func (cat *catalogue) setWeights(text string) error {
+	cat.weights = map[string]float64{}
+	if strings.TrimSpace(text) == catalogueEven {
+		for _, category := range cat.categories {
+			cat.weights[category] = 1
+		}
+		return nil
+	}
+
+	total := 0.0
+	for _, part := range strings.Split(text, ",") {
+		category, weightText, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return fmt.Errorf("%q is not category=weight, for example produce=30", part)
+		}
+		category = strings.ToLower(strings.TrimSpace(category))
+		if cat.items[category] == nil {
+			return fmt.Errorf("%q is not a category of the catalogue: %s", category, strings.Join(cat.categories, ", "))
+		}
+		weight, err := parseFloat(weightText, 0, 1000000)
+		if err != nil {
+			return fmt.Errorf("%s: %v", category, err)
+		}
+		cat.weights[category] = weight
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("%q: at least one category needs a weight above 0", text)
+	}
+	return nil
+}
+
+func readCatalogue(iStore int, defaultSettingsCode string) (*catalogue, error) {
+	prefix := "[Store " + strconv.Itoa(iStore) + "] "
+	code := "[store" + strconv.Itoa(iStore) + "]"
+
+	var cat *catalogue
+	err := readValidTextFromConsole(
+		prefix+"Which product catalogue (CSV file) do the customers buy from? [NONE] means anonymous products ",
+		false,
+		catalogueNone,
+		defaultSettingsCode,
+		code+"catalogue",
+		func(text string) (err error) {
+			cat = nil
+			if strings.ToUpper(strings.TrimSpace(text)) == catalogueNone {
+				return nil
+			}
+			cat, err = loadCatalogue(strings.TrimSpace(text))
+			return err
+		})
+	if err != nil || cat == nil {
+		return nil, err
+	}
+
+	err = readValidFromConsole(
+		prefix+"How popular is every category? Relative weights, for example produce=30,dairy=20 [EVEN] ",
+		catalogueEven,
+		defaultSettingsCode,
+		code+"categoryWeights",
+		cat.setWeights)
+	return cat, err
+}
+
+// pick puts an item of the catalogue in the basket: a category by popularity, then any
+// item of it. The scan time drawn for the product is for an easy barcode, the item's scan
+// difficulty and weighing loose produce come on top.
+func (cat *catalogue) pick(stream *rand.Rand, eProduct *product) {
+	total := 0.0
+	for _, category := range cat.categories {
+		total += cat.weights[category]
+	}
+	pick := stream.Float64() * total
+	category := ""
+	for _, candidate := range cat.categories {
+		if cat.weights[candidate] == 0 {
+			continue
+		}
+		category = candidate
+		pick -= cat.weights[candidate]
+		if pick < 0 {
+			break
+		}
+	}
+
+	items := cat.items[category]
+	item := items[stream.Intn(len(items))]
+	eProduct.item = &item
+	eProduct.processTimeSecond *= item.scanDifficulty
+	if item.weighed {
+		eProduct.processTimeSecond += weighingSeconds
+	}
+}
+
+// basketValue is what the customer's shopping costs, 0 without a catalogue.
+func (c *customer) basketValue() float64 {
+	value := 0.0
+	for _, eProduct := range c.products {
+		if eProduct.item != nil {
+			value += eProduct.item.price
+		}
+	}
+	return value
+}
+
+// hasAgeRestrictedItem tells if somebody has to check the customer's age.
+func (c *customer) hasAgeRestrictedItem() bool {
+	for _, eProduct := range c.products {
+		if eProduct.item != nil && eProduct.item.ageRestricted {
+			return true
+		}
+	}
+	return false
+}
+
+// printCatalogueReport is the items and the revenue by category for every checkout.
+func printCatalogueReport(out io.Writer, eStore *store) {
+	if eStore.catalogue == nil {
+		return
+	}
+	type sales struct {
+		items   int
+		revenue float64
+	}
+	byCheckout := map[int]map[string]*sales{}
+	totals := map[string]*sales{}
+	add := func(into map[string]*sales, category string, price float64) {
+		if into[category] == nil {
+			into[category] = &sales{}
+		}
+		into[category].items++
+		into[category].revenue += price
+	}
+	for _, eCustomer := range sortedCustomers(eStore) {
+		if eCustomer.outcome != outcomeServed {
+			continue
+		}
+		if byCheckout[eCustomer.checkoutId] == nil {
+			byCheckout[eCustomer.checkoutId] = map[string]*sales{}
+		}
+		for _, eProduct := range sortedProducts(eCustomer) {
+			add(byCheckout[eCustomer.checkoutId], eProduct.item.category, eProduct.item.price)
+			add(totals, eProduct.item.category, eProduct.item.price)
+		}
+	}
+
+	fmt.Fprintf(out, "%-12s %-14s %7s %10s\n", "Sales", "Category", "Items", "Revenue")
+	for _, kCheckout := range sortedCheckoutKeys(eStore) {
+		eCheckout := eStore.checkouts[kCheckout]
+		for _, category := range eStore.catalogue.categories {
+			if s := byCheckout[eCheckout.checkoutId][category]; s != nil {
+				fmt.Fprintf(out, "%-12s %-14s %7d %10.2f\n", eCheckout.label(), category, s.items, s.revenue)
+			}
+		}
+	}
+	for _, category := range eStore.catalogue.categories {
+		if s := totals[category]; s != nil {
+			fmt.Fprintf(out, "%-12s %-14s %7d %10.2f\n", "Total", category, s.items, s.revenue)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Exit codes, so scripts can tell a bad configuration from a run that went wrong.
+const (
+	exitOK              = 0
+	exitConfigError     = 2
+	exitSimulationError = 3
+)
+
+// promptFlags are the prompts that can be answered on the command line. The flag has
+// the same name as the code readFromConsole uses. Store and checkout settings apply to
+// every store and checkout, use --set "[store1][checkout4]maxItems=5" for a single one.
+var promptFlags = []struct {
+	code  string
+	usage string
+}{
+	{"oneHourIsInSeconds", "real world seconds for one simulated hour in real time mode"},
+	{"simulationMode", "E for discrete-event (instant), R for real time"},
+	{"numberOfStores", "how many stores to simulate"},
+	{"openingHours", "opening hours from-to, for example 9-22"},
+	{"busyRange", "how busy every hour is: Q, LB or B"},
+	{"weather", "B (bad), G (good) or E (excellent)"},
+	{"isFloorManager", "Y or N"},
+	{"routingStrategy", "how customers choose a checkout: random, shortest-queue, fewest-items, shortest-workload, desirability or nearest-entrance"},
+	{"closingPolicy", "what happens to customers queuing at closing time: SERVE or TURN-AWAY"},
+	{"queueTopology", "PER-LANE (a line per checkout), SHARED (one line for all) or HYBRID (shared plus an express line)"},
+	{"jockeying", "Y if customers move to a neighbouring line when it gets shorter"},
+	{"jockeyThreshold", "how many people shorter the neighbouring line has to be"},
+	{"jockeyPropensity", "chance a customer switches when it is, as a range, for example 0.2-0.8"},
+	{"numberOfCustomers", "customers a day as a range, for example 350-450"},
+	{"numberOfProducts", "products per customer as a range, for example 1-100"},
+	{"productProcessTime", "seconds to scan a product as a range, for example 0.5-6"},
+	{"maxQueueTime", "minutes a customer queues before giving up as a range, for example 15-30"},
+	{"maxQueueCustomers", "queue length that makes a customer give up as a range, for example 5-10"},
+	{"maxQueueItems", "items in the line that make a customer look elsewhere as a range, 0 to only count people"},
+	{"shoppingTimePerItem", "seconds in the aisles per product as a range, for example 15-30, 0 to skip shopping"},
+	{"catalogue", "CSV file with the products the customers buy, see scenarios/catalogue.csv, or NONE"},
+	{"categoryWeights", "how popular the categories of the catalogue are, for example produce=30,dairy=20, or EVEN"},
+	{"exceptionChance_id-check", "chance the cashier asks a customer for ID, 0 to 1"},
+	{"exceptionChance_price-override", "chance of a price override per item, needs the supervisor, 0 to 1"},
+	{"exceptionChance_void", "chance of a void per item, needs the supervisor, 0 to 1"},
+	{"exceptionChance_manual-key-in", "chance a barcode has to be keyed in per item, 0 to 1"},
+	{"exceptionTime_id-check", "seconds an ID check takes as a range"},
+	{"exceptionTime_price-override", "seconds a price override takes as a range"},
+	{"exceptionTime_void", "seconds a void takes as a range"},
+	{"exceptionTime_manual-key-in", "seconds keying in a barcode takes as a range"},
+	{"hourlyWage", "what a cashier is paid per hour, in the store and at every checkout"},
+	{"itemPrice", "what an item costs as a range when there is no catalogue, for example 1-4"},
+	{"grossMargin", "part of the takings the store keeps after paying for the goods, 0 to 1"},
+	{"numberOfCheckouts", "checkouts per store"},
+	{"cashierEfficiency", "cashier efficiency, 0.1 (really slow) to 1.9 (really fast)"},
+	{"maxItems", "maximum items per checkout, 0 means unlimited"},
+	{"checkoutDesirability", "how desirable the checkouts are based on their location"},
+	{"lanePayment", "which payments a checkout takes: ANY, CASH-ONLY or CARD-ONLY"},
+	{"expressHours", "hours a checkout is an express lane and for how many items, for example 12-14:10,17-19:10, or NEVER"},
+	{"accessible", "Y if a checkout is an accessible lane, serving customers that need it first"},
+	{"expressCheatChance", "chance a customer takes up to half as many items again as the limit to an express lane, 0 to 1"},
+	{"accessibilityNeed", "chance a customer needs an accessible lane, 0 to 1"},
+	{"roster", "who works a checkout and when, for example ANNA:1.2@9-14/12:00+15,BEN@14-22, or ALL-DAY"},
+	{"handoverTime", "seconds it takes when a till changes cashier"},
+	{"reserveStaff", "reserve staff the floor manager can put on a standby till, 0 for none"},
+	{"openQueueLength", "people waiting per open till, in every line, that makes the floor manager open a standby till"},
+	{"openWaitMinutes", "minutes in a line that make the floor manager open a standby till, 0 for never"},
+	{"closeQueueLength", "people waiting per open till, in every line, at which the floor manager closes a standby till"},
+	{"ruleMinutes", "minutes the lines have to be busy or quiet before the floor manager acts"},
+	{"redirectMaxItems", "the floor manager sends customers with at most this many items to an express lane, 0 for no"},
+	{"paymentMix", "how customers pay, for example contactless=50,chip-and-pin=15,cash=15,mobile-wallet=12,voucher=3,split=5"},
+	{"paymentTime_contactless", "seconds to pay contactless as a range"},
+	{"paymentTime_chip-and-pin", "seconds to pay by chip and PIN as a range"},
+	{"paymentTime_cash", "seconds to pay cash as a range, without the change"},
+	{"paymentTime_mobile-wallet", "seconds to pay with a mobile wallet as a range"},
+	{"paymentTime_voucher", "seconds to pay with a voucher as a range"},
+	{"changeTime", "seconds to count the change for cash as a range"},
+	{"declineChance", "chance a card is declined, 0 to 1"},
+	{"selfCheckouts", "self-service kiosks per store, 0 for none"},
+	{"selfCheckoutMaxItems", "maximum items at the kiosks, 0 means unlimited"},
+	{"selfScanFactor", "how much slower customers scan than a cashier, for example 1.5"},
+	{"selfCheckoutPreference", "chance a customer prefers a kiosk, 0 to 1"},
+	{"unexpectedItemChance", "chance of an unexpected item in the bagging area per item, 0 to 1"},
+	{"ageCheckChance", "chance a kiosk customer needs an age check, 0 to 1"},
+	{"interventionTime", "seconds the attendant takes at a kiosk as a range, for example 20-60"},
+}
+
+// promptOverrides holds the answers given on the command line, by prompt code.
+var promptOverrides = map[string]string{}
+
+var promptScope = regexp.MustCompile(`\[(store|checkout)\d+\]`)
+
+// promptOverride finds a command line answer for code, first for that exact store or
+// checkout (--set) and then for every store and checkout (--openingHours and friends).
+func promptOverride(code string) (string, bool) {
+	if value, ok := promptOverrides[code]; ok {
+		return value, true
+	}
+	generic := promptScope.ReplaceAllString(code, "")
+	if value, ok := promptOverrides[generic]; ok {
+		return value, true
+	}
+	if strings.HasPrefix(generic, "busyRange_") {
+		value, ok := promptOverrides["busyRange"]
+		return value, ok
+	}
+	return "", false
+}
+
+// settingList collects repeated --set code=value flags.
+type settingList map[string]string
+
+func (s settingList) String() string { return "" }
+
+func (s settingList) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return errors.New("expected code=value, for example [store1][checkout4]maxItems=5")
+	}
+	s[parts[0]] = parts[1]
+	return nil
+}
+
+func runCLI(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		// No subcommand, behave like we always did and ask in the console.
+		return runCommand("run", args, true)
+	}
+
+	switch args[0] {
+	case "run":
+		return runCommand("run", args[1:], false)
+	case "report":
+		return runCommand("report", args[1:], false)
+	case "batch":
+		return batchCommand(args[1:])
+	case "optimise":
+		return optimiseCommand(args[1:])
+	case "list-scenarios":
+		return listScenariosCommand(args[1:])
+	case "validate-config":
+		return validateConfigCommand(args[1:])
+	case "help", "-h", "--help":
+		printUsage(os.Stdout)
+		return exitOK
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+	return exitConfigError
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: supermarket [command] [flags]")
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  run              run a simulated day, every prompt has a matching flag (see run -h)")
+	fmt.Fprintln(out, "  report           run a simulated day quietly and print only the end of day report")
+	fmt.Fprintln(out, "  batch            run a scenario on many seeds in parallel, every KPI with its 95% confidence interval")
+	fmt.Fprintln(out, "  optimise         find the fewest tills per hour that meet a service target")
+	fmt.Fprintln(out, "  list-scenarios   list the built-in scenarios and those in --config")
+	fmt.Fprintln(out, "  validate-config  check a scenario file given with --config")
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Without a command the simulation asks for its settings in the console.")
+	fmt.Fprintln(out, "")
+	fmt.Fprintf(out, "Exit codes: %d success, %d configuration error, %d simulation failure.\n",
+		exitOK, exitConfigError, exitSimulationError)
+}
+
+// parseFlags parses args with fs, answering -h with exitOK and anything else with exitConfigError.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitConfigError, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument %q\n", fs.Arg(0))
+		return exitConfigError, false
+	}
+	return exitOK, true
+}
+
+// loadConfig registers the built-in scenarios and then the ones in path, if any.
+func loadConfig(path string) ([]string, error) {
+	registerBuiltInScenarios()
+	if path == "" {
+		return nil, nil
+	}
+	return loadScenarioFile(path)
+}
+
+// scenarioFlags pick the day to simulate: a scenario file, a scenario and an answer for
+// any prompt.
+type scenarioFlags struct {
+	configPath *string
+	scenario   *string
+	settings   settingList
+	answers    map[string]*string
+}
+
+func addScenarioFlags(fs *flag.FlagSet) *scenarioFlags {
+	f := &scenarioFlags{settings: settingList{}, answers: map[string]*string{}}
+	f.configPath = fs.String("config", "", "YAML or JSON scenario file, its scenarios are offered next to the built-in ones")
+	f.scenario = fs.String("scenario", "", "Y for all defaults, N to be asked, or a scenario name (see list-scenarios)")
+	fs.Var(f.settings, "set", "answer a single prompt, code=value, can be repeated")
+	for _, prompt := range promptFlags {
+		f.answers[prompt.code] = fs.String(prompt.code, "", prompt.usage)
+	}
+	return f
+}
+
+// apply hands the answers given on the command line to the prompts and loads the
+// scenario file, once fs is parsed.
+func (f *scenarioFlags) apply(fs *flag.FlagSet) error {
+	fs.Visit(func(given *flag.Flag) {
+		if answer, ok := f.answers[given.Name]; ok {
+			promptOverrides[given.Name] = *answer
+		}
+	})
+	for code, value := range f.settings {
+		promptOverrides[code] = value
+	}
+	if *f.scenario != "" {
+		promptOverrides["defaultSettingsCode"] = *f.scenario
+	} else if !interactive {
+		promptOverrides["defaultSettingsCode"] = "Y"
+	}
+
+	_, err := loadConfig(*f.configPath)
+	return err
+}
+
+func runCommand(name string, args []string, askInConsole bool) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed for the random streams, the same seed gives the same day (0 picks one from the clock)")
+	fs.BoolVar(&interactive, "interactive", askInConsole, "ask in the console for anything not given as a flag")
+	journeysPath := fs.String("journeys", "", "write one record per customer to this file")
+	journeysFormat := fs.String("journeys-format", "", "csv or jsonl, by default taken from the --journeys file extension")
+	scenario := addScenarioFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if err := scenario.apply(fs); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not load config: "+err.Error())
+		return exitConfigError
+	}
+
+	if *journeysPath != "" {
+		if _, err := journeyFormat(*journeysPath, *journeysFormat); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not open journey export: "+err.Error())
+			return exitConfigError
+		}
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	fmt.Printf("Seed: %d (run again with --seed %d to get the same day)\n", *seed, *seed)
+
+	if name == "report" {
+		console = io.Discard
+	}
+	sim, err := setupSimulation(*seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Configuration error: "+err.Error())
+		return exitConfigError
+	}
+	if name == "report" {
+		sim.out = io.Discard
+	}
+
+	var journeys *journeyExport
+	if *journeysPath != "" {
+		if journeys, err = openJourneyExport(*journeysPath, *journeysFormat); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not open journey export: "+err.Error())
+			return exitConfigError
+		}
+		defer journeys.close()
+	}
+
+	// Ctrl+C stops the day where it is.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := sim.run(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Simulation failed: "+err.Error())
+		return exitSimulationError
+	}
+
+	printSummary(os.Stdout, sim.stores)
+	printStatistics(os.Stdout, sim)
+
+	if hasJockeying(sim) {
+		// The same day again without anybody switching lines, to see what jockeying changed.
+		baseline, err := replaySimulation(*seed, map[string]string{"jockeying": "N", "simulationMode": "E"})
+		if err == nil {
+			err = baseline.run(ctx)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Simulation failed: "+err.Error())
+			return exitSimulationError
+		}
+		printJockeyingReport(os.Stdout, sim, baseline)
+	}
+
+	if hasFloorManagerActions(sim) {
+		// The same day again where the floor manager only points customers to a line.
+		baseline, err := replaySimulation(*seed,
+			map[string]string{"reserveStaff": "0", "redirectMaxItems": "0", "simulationMode": "E"})
+		if err == nil {
+			err = baseline.run(ctx)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Simulation failed: "+err.Error())
+			return exitSimulationError
+		}
+		printFloorManagerReport(os.Stdout, sim, baseline)
+	}
+
+	if journeys != nil {
+		if err := journeys.write(sim.stores); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not write journey export: "+err.Error())
+			return exitSimulationError
+		}
+	}
+	return exitOK
+}
+
+func listScenariosCommand(args []string) int {
+	fs := flag.NewFlagSet("list-scenarios", flag.ContinueOnError)
+	configPath := fs.String("config", "", "YAML or JSON scenario file to list as well")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if _, err := loadConfig(*configPath); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not load config: "+err.Error())
+		return exitConfigError
+	}
+
+	for _, name := range scenarioNames() {
+		code := strings.ToUpper(name)
+		switch {
+		case code == "Y":
+			fmt.Printf("%-12s all default settings\n", name)
+		case code == "N":
+			fmt.Printf("%-12s ask for every setting\n", name)
+		case fileScenarios[code] != "":
+			fmt.Printf("%-12s from %s\n", name, fileScenarios[code])
+		default:
+			fmt.Printf("%-12s built-in\n", name)
+		}
+	}
+	return exitOK
+}
+
+func validateConfigCommand(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	configPath := fs.String("config", "", "YAML or JSON scenario file to check")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *configPath == "" {
+		fmt.Fprintln(os.Stderr, "validate-config needs --config")
+		return exitConfigError
+	}
+
+	names, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid: "+err.Error())
+		return exitConfigError
+	}
+
+	// Build every scenario the way run would, without asking anything.
+	interactive = false
+	console = io.Discard
+	failed := false
+	for _, name := range names {
+		promptOverrides["defaultSettingsCode"] = name
+		if _, err := setupSimulation(1); err != nil {
+			fmt.Printf("%s: invalid: %v\n", name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: ok\n", name)
+	}
+
+	if failed {
+		return exitConfigError
+	}
+	return exitOK
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A scenario file describes one or more scenarios the same way the prompts do.
+// Loading a file turns it into defaultScenarios entries, so readFromConsole picks
+// the values up exactly like the built-in SCENARIO1..3. Any value the file leaves
+// out is asked for in the console.
+//
+//	scenarios:
+//	  scenario4:
+//	    oneHourIsInSeconds: 1
+//	    stores:
+//	      - openingHours: 9-22
+//	        weather: G
+//	        isFloorManager: Y
+//	        busyRanges:
+//	          "9": q
+//	          "13": b
+//	        customers:
+//	          numberOfCustomers: 300-400
+//	          numberOfProducts: 1-120
+//	        checkouts:
+//	          - cashierEfficiency: 1
+//	            maxItems: 0
+//	            checkoutDesirability: 1
+//	            roster: ANNA@9-15/12:00+30,BEN@15-22
+type scenarioFile struct {
+	Scenarios map[string]scenarioConfig `json:"scenarios"`
+}
+
+type scenarioConfig struct {
+	OneHourIsInSeconds configValue   `json:"oneHourIsInSeconds"`
+	SimulationMode     configValue   `json:"simulationMode"`
+	NumberOfStores     configValue   `json:"numberOfStores"`
+	Stores             []storeConfig `json:"stores"`
+}
+
+type storeConfig struct {
+	OpeningHours      configValue            `json:"openingHours"`
+	BusyRanges        map[string]configValue `json:"busyRanges"`
+	Weather           configValue            `json:"weather"`
+	IsFloorManager    configValue            `json:"isFloorManager"`
+	FloorManager      floorManagerConfig     `json:"floorManager"`
+	RoutingStrategy   configValue            `json:"routingStrategy"`
+	ClosingPolicy     configValue            `json:"closingPolicy"`
+	QueueTopology     configValue            `json:"queueTopology"`
+	Jockeying         configValue            `json:"jockeying"`
+	JockeyThreshold   configValue            `json:"jockeyThreshold"`
+	JockeyPropensity  configValue            `json:"jockeyPropensity"`
+	Customers         customerConfig         `json:"customers"`
+	NumberOfCheckouts configValue            `json:"numberOfCheckouts"`
+	HandoverTime      configValue            `json:"handoverTime"`
+	Checkouts         []checkoutConfig       `json:"checkouts"`
+	SelfCheckout      selfCheckoutConfig     `json:"selfCheckout"`
+	Payments          paymentConfig          `json:"payments"`
+	Lanes             laneConfig             `json:"lanes"`
+	Exceptions        exceptionConfig        `json:"exceptions"`
+	Economics         economicsConfig        `json:"economics"`
+}
+
+// customerConfig holds the ranges the customers are drawn from.
+type customerConfig struct {
+	NumberOfCustomers   configValue `json:"numberOfCustomers"`
+	NumberOfProducts    configValue `json:"numberOfProducts"`
+	ProductProcessTime  configValue `json:"productProcessTime"`
+	MaxQueueTime        configValue `json:"maxQueueTime"`
+	MaxQueueCustomers   configValue `json:"maxQueueCustomers"`
+	MaxQueueItems       configValue `json:"maxQueueItems"`
+	ShoppingTimePerItem configValue `json:"shoppingTimePerItem"`
+	Catalogue           configValue `json:"catalogue"`
+	CategoryWeights     configValue `json:"categoryWeights"`
+}
+
+// selfCheckoutConfig is the bank of self-service kiosks, see selfcheckout.go.
+type selfCheckoutConfig struct {
+	Kiosks               configValue `json:"kiosks"`
+	MaxItems             configValue `json:"maxItems"`
+	ScanFactor           configValue `json:"scanFactor"`
+	Preference           configValue `json:"preference"`
+	UnexpectedItemChance configValue `json:"unexpectedItemChance"`
+	AgeCheckChance       configValue `json:"ageCheckChance"`
+	InterventionTime     configValue `json:"interventionTime"`
+}
+
+// paymentConfig is how customers pay, see payment.go. times is by method.
+type paymentConfig struct {
+	Mix           configValue            `json:"mix"`
+	Times         map[string]configValue `json:"times"`
+	ChangeTime    configValue            `json:"changeTime"`
+	DeclineChance configValue            `json:"declineChance"`
+}
+
+// exceptionConfig is how likely each scanning exception is and its seconds, by kind, see
+// exceptions.go.
+type exceptionConfig struct {
+	Chances map[string]configValue `json:"chances"`
+	Times   map[string]configValue `json:"times"`
+}
+
+// economicsConfig is the money side of the day, see economics.go.
+type economicsConfig struct {
+	HourlyWage  configValue `json:"hourlyWage"`
+	ItemPrice   configValue `json:"itemPrice"`
+	GrossMargin configValue `json:"grossMargin"`
+}
+
+// laneConfig are the customers that go with the lane rules, see lanes.go.
+type laneConfig struct {
+	ExpressCheatChance configValue `json:"expressCheatChance"`
+	AccessibilityNeed  configValue `json:"accessibilityNeed"`
+}
+
+// floorManagerConfig are the floor manager's rules, see floormanager.go.
+type floorManagerConfig struct {
+	ReserveStaff     configValue `json:"reserveStaff"`
+	OpenQueueLength  configValue `json:"openQueueLength"`
+	OpenWaitMinutes  configValue `json:"openWaitMinutes"`
+	CloseQueueLength configValue `json:"closeQueueLength"`
+	RuleMinutes      configValue `json:"ruleMinutes"`
+	RedirectMaxItems configValue `json:"redirectMaxItems"`
+}
+
+type checkoutConfig struct {
+	CashierEfficiency    configValue `json:"cashierEfficiency"`
+	MaxItems             configValue `json:"maxItems"`
+	CheckoutDesirability configValue `json:"checkoutDesirability"`
+	Roster               configValue `json:"roster"`
+	LanePayment          configValue `json:"lanePayment"`
+	ExpressHours         configValue `json:"expressHours"`
+	Accessible           configValue `json:"accessible"`
+	HourlyWage           configValue `json:"hourlyWage"`
+}
+
+// configValue is a setting exactly as it would be typed at the prompt. Files may write
+// it as a string, a number or a boolean, an empty value means "ask in the console".
+type configValue string
+
+func (v *configValue) UnmarshalJSON(data []byte) error {
+	var text string
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		text = ""
+	case len(data) > 0 && data[0] == '"':
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	default:
+		text = string(data)
+	}
+
+	switch strings.ToLower(text) {
+	case "true", "yes":
+		text = "Y"
+	case "false", "no":
+		text = "N"
+	}
+	*v = configValue(strings.TrimSpace(text))
+	return nil
+}
+
+// fileScenarios maps the scenario codes loaded from a file to that file. For those
+// a missing value falls back to the console prompt instead of the built-in default.
+var fileScenarios = map[string]string{}
+
+// loadScenarioFile reads a .yaml, .yml or .json scenario file into defaultScenarios
+// and returns the names of the scenarios it defines.
+func loadScenarioFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		tree, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		// Once read, the YAML tree is decoded the same way as a JSON file.
+		if data, err = json.Marshal(tree); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	case ".json":
+	default:
+		return nil, fmt.Errorf("%s: unknown config format, use .yaml, .yml or .json", path)
+	}
+
+	var file scenarioFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(file.Scenarios) == 0 {
+		return nil, fmt.Errorf("%s: no scenarios defined", path)
+	}
+
+	var names []string
+	for name, eScenario := range file.Scenarios {
+		code := strings.ToUpper(name)
+		if code == "Y" || code == "N" {
+			return nil, fmt.Errorf("%s: %q can not be used as a scenario name", path, name)
+		}
+		eScenario.flatten(code, filepath.Dir(path), defaultScenarios)
+		fileScenarios[code] = path
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// filePath is a setting that names a file. A relative path in a scenario file is relative
+// to the file, not to wherever the program is run from.
+func filePath(dir string, value configValue) configValue {
+	text := string(value)
+	if text == "" || strings.EqualFold(text, catalogueNone) || filepath.IsAbs(text) {
+		return value
+	}
+	return configValue(filepath.Join(dir, text))
+}
+
+// scenarioNames lists what can be answered to the first prompt, built-in ones first.
+func scenarioNames() []string {
+	seen := map[string]bool{}
+	var fromFiles []string
+	for key := range defaultScenarios {
+		code := key[:strings.Index(key, "_")]
+		if !seen[code] {
+			seen[code] = true
+			if fileScenarios[code] != "" {
+				fromFiles = append(fromFiles, strings.ToLower(code))
+			}
+		}
+	}
+	for code := range fileScenarios {
+		if !seen[code] {
+			fromFiles = append(fromFiles, strings.ToLower(code))
+		}
+	}
+	sort.Strings(fromFiles)
+
+	names := []string{"Y", "N"}
+	for code := range seen {
+		if fileScenarios[code] == "" {
+			names = append(names, strings.ToLower(code))
+		}
+	}
+	sort.Strings(names[2:])
+	return append(names, fromFiles...)
+}
+
+// isKnownScenario tells if code can be used as defaultSettingsCode.
+func isKnownScenario(code string) bool {
+	for _, name := range scenarioNames() {
+		if strings.ToUpper(name) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten writes the scenario using the same codes readFromConsole asks for. dir is where
+// the scenario file is, the files it names are found from there.
+func (sc scenarioConfig) flatten(code string, dir string, into map[string]string) {
+	set := func(key string, value configValue) {
+		if value != "" {
+			into[code+"_"+key] = string(value)
+		}
+	}
+
+	set("oneHourIsInSeconds", sc.OneHourIsInSeconds)
+	set("simulationMode", sc.SimulationMode)
+	numberOfStores := sc.NumberOfStores
+	if numberOfStores == "" && len(sc.Stores) > 0 {
+		numberOfStores = configValue(strconv.Itoa(len(sc.Stores)))
+	}
+	set("numberOfStores", numberOfStores)
+
+	for iStore, eStore := range sc.Stores {
+		storeKey := "[store" + strconv.Itoa(iStore+1) + "]"
+		set(storeKey+"openingHours", eStore.OpeningHours)
+		for hour, level := range eStore.BusyRanges {
+			set(storeKey+"busyRange_"+strings.TrimSpace(hour), level)
+		}
+		set(storeKey+"weather", eStore.Weather)
+		set(storeKey+"isFloorManager", eStore.IsFloorManager)
+		set(storeKey+"reserveStaff", eStore.FloorManager.ReserveStaff)
+		set(storeKey+"openQueueLength", eStore.FloorManager.OpenQueueLength)
+		set(storeKey+"openWaitMinutes", eStore.FloorManager.OpenWaitMinutes)
+		set(storeKey+"closeQueueLength", eStore.FloorManager.CloseQueueLength)
+		set(storeKey+"ruleMinutes", eStore.FloorManager.RuleMinutes)
+		set(storeKey+"redirectMaxItems", eStore.FloorManager.RedirectMaxItems)
+		set(storeKey+"routingStrategy", eStore.RoutingStrategy)
+		set(storeKey+"closingPolicy", eStore.ClosingPolicy)
+		set(storeKey+"queueTopology", eStore.QueueTopology)
+		set(storeKey+"jockeying", eStore.Jockeying)
+		set(storeKey+"jockeyThreshold", eStore.JockeyThreshold)
+		set(storeKey+"jockeyPropensity", eStore.JockeyPropensity)
+		set(storeKey+"numberOfCustomers", eStore.Customers.NumberOfCustomers)
+		set(storeKey+"numberOfProducts", eStore.Customers.NumberOfProducts)
+		set(storeKey+"productProcessTime", eStore.Customers.ProductProcessTime)
+		set(storeKey+"maxQueueTime", eStore.Customers.MaxQueueTime)
+		set(storeKey+"maxQueueCustomers", eStore.Customers.MaxQueueCustomers)
+		set(storeKey+"maxQueueItems", eStore.Customers.MaxQueueItems)
+		set(storeKey+"shoppingTimePerItem", eStore.Customers.ShoppingTimePerItem)
+		set(storeKey+"catalogue", filePath(dir, eStore.Customers.Catalogue))
+		set(storeKey+"categoryWeights", eStore.Customers.CategoryWeights)
+		set(storeKey+"selfCheckouts", eStore.SelfCheckout.Kiosks)
+		set(storeKey+"selfCheckoutMaxItems", eStore.SelfCheckout.MaxItems)
+		set(storeKey+"selfScanFactor", eStore.SelfCheckout.ScanFactor)
+		set(storeKey+"selfCheckoutPreference", eStore.SelfCheckout.Preference)
+		set(storeKey+"unexpectedItemChance", eStore.SelfCheckout.UnexpectedItemChance)
+		set(storeKey+"ageCheckChance", eStore.SelfCheckout.AgeCheckChance)
+		set(storeKey+"interventionTime", eStore.SelfCheckout.InterventionTime)
+		set(storeKey+"paymentMix", eStore.Payments.Mix)
+		for method, seconds := range eStore.Payments.Times {
+			set(storeKey+"paymentTime_"+strings.TrimSpace(method), seconds)
+		}
+		set(storeKey+"changeTime", eStore.Payments.ChangeTime)
+		set(storeKey+"declineChance", eStore.Payments.DeclineChance)
+		set(storeKey+"expressCheatChance", eStore.Lanes.ExpressCheatChance)
+		for kind, chance := range eStore.Exceptions.Chances {
+			set(storeKey+"exceptionChance_"+strings.TrimSpace(kind), chance)
+		}
+		for kind, seconds := range eStore.Exceptions.Times {
+			set(storeKey+"exceptionTime_"+strings.TrimSpace(kind), seconds)
+		}
+		set(storeKey+"accessibilityNeed", eStore.Lanes.AccessibilityNeed)
+		set(storeKey+"hourlyWage", eStore.Economics.HourlyWage)
+		set(storeKey+"itemPrice", eStore.Economics.ItemPrice)
+		set(storeKey+"grossMargin", eStore.Economics.GrossMargin)
+
+		numberOfCheckouts := eStore.NumberOfCheckouts
+		if numberOfCheckouts == "" && len(eStore.Checkouts) > 0 {
+			numberOfCheckouts = configValue(strconv.Itoa(len(eStore.Checkouts)))
+		}
+		set(storeKey+"numberOfCheckouts", numberOfCheckouts)
+		set(storeKey+"handoverTime", eStore.HandoverTime)
+
+		for iCheckout, eCheckout := range eStore.Checkouts {
+			checkoutKey := storeKey + "[checkout" + strconv.Itoa(iCheckout+1) + "]"
+			set(checkoutKey+"cashierEfficiency", eCheckout.CashierEfficiency)
+			set(checkoutKey+"maxItems", eCheckout.MaxItems)
+			set(checkoutKey+"checkoutDesirability", eCheckout.CheckoutDesirability)
+			set(checkoutKey+"roster", eCheckout.Roster)
+			set(checkoutKey+"lanePayment", eCheckout.LanePayment)
+			set(checkoutKey+"expressHours", eCheckout.ExpressHours)
+			set(checkoutKey+"accessible", eCheckout.Accessible)
+			set(checkoutKey+"hourlyWage", eCheckout.HourlyWage)
+		}
+	}
+}
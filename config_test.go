@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadTestScenario writes text to a scenario file called name and loads it, taking what
+// it added to the scenarios away again once the test is over.
+func loadTestScenario(t *testing.T, name, text string) ([]string, error) {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for key := range defaultScenarios {
+			if fileScenarios[key[:strings.Index(key, "_")]] == path {
+				delete(defaultScenarios, key)
+			}
+		}
+		for code, from := range fileScenarios {
+			if from == path {
+				delete(fileScenarios, code)
+			}
+		}
+	})
+	return loadScenarioFile(path)
+}
+
+func TestConfigValue(t *testing.T) {
+	tests := []struct {
+		json string
+		want configValue
+	}{
+		{`"9-22"`, "9-22"},
+		{`" G "`, "G"},
+		{`12`, "12"},
+		{`0.25`, "0.25"},
+		{`true`, "Y"},
+		{`false`, "N"},
+		{`"yes"`, "Y"},
+		{`"No"`, "N"},
+		{`null`, ""},
+	}
+	for _, test := range tests {
+		var got configValue
+		if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+			t.Errorf("configValue(%s) failed: %v", test.json, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("configValue(%s) = %q, want %q", test.json, got, test.want)
+		}
+	}
+}
+
+func TestLoadScenarioFile(t *testing.T) {
+	yaml := `
+scenarios:
+  testYaml:
+    oneHourIsInSeconds: 1
+    stores:
+      - openingHours: 9-22
+        isFloorManager: true
+        busyRanges:
+          "9": q
+        customers:
+          numberOfCustomers: 300-400
+        checkouts:
+          - maxItems: 0
+            roster: ANNA@9-15,BEN@15-22
+          - maxItems: 10
+`
+	json := `{"scenarios": {"testJson": {"stores": [{"weather": "G", "checkouts": [{"cashierEfficiency": 1.5}]}]}}}`
+
+	tests := []struct {
+		file string
+		text string
+		name string
+		want map[string]string
+	}{
+		{"scenario.yaml", yaml, "testyaml", map[string]string{
+			"TESTYAML_oneHourIsInSeconds":          "1",
+			"TESTYAML_numberOfStores":              "1",
+			"TESTYAML_[store1]openingHours":        "9-22",
+			"TESTYAML_[store1]isFloorManager":      "Y",
+			"TESTYAML_[store1]busyRange_9":         "q",
+			"TESTYAML_[store1]numberOfCustomers":   "300-400",
+			"TESTYAML_[store1]numberOfCheckouts":   "2",
+			"TESTYAML_[store1][checkout1]maxItems": "0",
+			"TESTYAML_[store1][checkout1]roster":   "ANNA@9-15,BEN@15-22",
+			"TESTYAML_[store1][checkout2]maxItems": "10",
+		}},
+		{"scenario.json", json, "testjson", map[string]string{
+			"TESTJSON_numberOfStores":                       "1",
+			"TESTJSON_[store1]weather":                      "G",
+			"TESTJSON_[store1]numberOfCheckouts":            "1",
+			"TESTJSON_[store1][checkout1]cashierEfficiency": "1.5",
+		}},
+	}
+	for _, test := range tests {
+		names, err := loadTestScenario(t, test.file, test.text)
+		if err != nil {
+			t.Errorf("%s: loadScenarioFile failed: %v", test.file, err)
+			continue
+		}
+		if len(names) != 1 || names[0] != test.name {
+			t.Errorf("%s: loadScenarioFile = %v, want [%s]", test.file, names, test.name)
+		}
+		for key, want := range test.want {
+			if got := defaultScenarios[key]; got != want {
+				t.Errorf("%s: %s = %q, want %q", test.file, key, got, want)
+			}
+		}
+		if _, asked := defaultScenarios[strings.ToUpper(test.name)+"_simulationMode"]; asked {
+			t.Errorf("%s: simulationMode is set but the file leaves it out", test.file)
+		}
+	}
+}
+
+func TestLoadScenarioFileErrors(t *testing.T) {
+	tests := []struct {
+		file string
+		text string
+		want string
+	}{
+		{"scenario.toml", "", "unknown config format"},
+		{"scenario.yaml", "scenarios:\n\tbad: 1\n", "tabs are not allowed"},
+		{"scenario.yaml", "scenarios:\n", "cannot unmarshal string"},
+		{"scenario.json", `{"scenarios": {}}`, "no scenarios defined"},
+		{"scenario.json", `{"scenarios": {"y": {}}}`, "can not be used as a scenario name"},
+		{"scenario.yaml", "scenarios:\n  s:\n    wether: G\n", "unknown field \"wether\""},
+		{"scenario.json", `{"scenarios": `, "unexpected EOF"},
+	}
+	for _, test := range tests {
+		_, err := loadTestScenario(t, test.file, test.text)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s %q: error = %v, want %q", test.file, test.text, err, test.want)
+		}
+	}
+}
+
+func TestLoadScenarioFileFindsFilesNextToIt(t *testing.T) {
+	names, err := loadTestScenario(t, "scenario.yaml", `
+scenarios:
+  testFiles:
+    stores:
+      - customers:
+          catalogue: catalogue.csv
+      - customers:
+          catalogue: NONE
+      - customers:
+          catalogue: /data/catalogue.csv
+`)
+	if err != nil || len(names) != 1 {
+		t.Fatalf("loadScenarioFile = %v, %v", names, err)
+	}
+	dir := filepath.Dir(fileScenarios["TESTFILES"])
+	want := map[string]string{
+		"TESTFILES_[store1]catalogue": filepath.Join(dir, "catalogue.csv"),
+		"TESTFILES_[store2]catalogue": "NONE",
+		"TESTFILES_[store3]catalogue": "/data/catalogue.csv",
+	}
+	for key, path := range want {
+		if got := defaultScenarios[key]; got != path {
+			t.Errorf("%s = %q, want %q", key, got, path)
+		}
+	}
+}
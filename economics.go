@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+)
+
+// economicsSettings put money on the day. Without a catalogue every item is worth a price
+// drawn from itemPrice. grossMargin is the part of the takings the store keeps once the
+// goods are paid for.
+type economicsSettings struct {
+	hourlyWage    float64
+	itemPrice     floatRange
+	grossMargin   float64
+	fromCatalogue bool
+}
+
+func readEconomicsSettings(iStore int, hasCatalogue bool, defaultSettingsCode string) (economicsSettings, error) {
+	settings := economicsSettings{fromCatalogue: hasCatalogue}
+	prefix := "[Store " + strconv.Itoa(iStore) + "] "
+	code := "[store" + strconv.Itoa(iStore) + "]"
+
+	var err error
+	settings.hourlyWage, err = readFloat(
+		prefix+"What is a cashier paid per hour? [12] ",
+		"12",
+		defaultSettingsCode,
+		code+"hourlyWage",
+		0, 10000)
+	if err != nil {
+		return settings, err
+	}
+	if !hasCatalogue {
+		settings.itemPrice, err = readFloatRange(
+			prefix+"What does an item cost? Range response [1-4] means from 1 to 4 per item ",
+			"1-4",
+			defaultSettingsCode,
+			code+"itemPrice",
+			0, 100000)
+		if err != nil {
+			return settings, err
+		}
+	}
+	settings.grossMargin, err = readFloat(
+		prefix+"What part of the takings does the store keep after paying for the goods? From 0 to 1 [0.25] ",
+		"0.25",
+		defaultSettingsCode,
+		code+"grossMargin",
+		0, 1)
+	return settings, err
+}
+
+// readCheckoutWages asks what the cashiers of every staffed checkout are paid, the store's
+// hourlyWage unless the checkout pays differently. The reserve staff on the standby tills
+// are paid the store's wage.
+func readCheckoutWages(iStore int, checkouts map[string]*checkout, storeWage float64, defaultSettingsCode string) error {
+	defaultWage := strconv.FormatFloat(storeWage, 'g', -1, 64)
+	for iCheckout := 1; iCheckout <= len(checkouts); iCheckout++ {
+		eCheckout := checkouts["checkout"+strconv.Itoa(iCheckout)]
+		if eCheckout.selfService {
+			continue
+		}
+		eCheckout.hourlyWage = storeWage
+		if eCheckout.standby {
+			continue
+		}
+		var err error
+		eCheckout.hourlyWage, err = readFloat(
+			"[Store "+strconv.Itoa(iStore)+"][Checkout "+strconv.Itoa(eCheckout.checkoutId)+"] "+
+				"What are the cashiers of this checkout paid per hour? ["+defaultWage+"] ",
+			defaultWage,
+			defaultSettingsCode,
+			"[store"+strconv.Itoa(iStore)+"][checkout"+strconv.Itoa(eCheckout.checkoutId)+"]hourlyWage",
+			0, 10000)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// priceBasket is the catalogue prices of the customer's shopping, or without a catalogue
+// an itemPrice drawn for every item.
+func (settings economicsSettings) priceBasket(stream *rand.Rand, customer *customer) float64 {
+	if settings.fromCatalogue {
+		return customer.basketValue()
+	}
+	value := 0.0
+	for i := 0; i < customer.items; i++ {
+		value += drawSeconds(stream, settings.itemPrice)
+	}
+	return value
+}
+
+// dayEconomics is the money side of a store's day. Lost revenue is the shopping of the
+// customers that gave up queuing, did not join a line at all or could not pay.
+type dayEconomics struct {
+	revenue     float64
+	lostRevenue float64
+	labour      float64
+	profit      float64
+}
+
+// labourHours is every hour somebody was on a till, plus the kiosk attendant while the
+// store was open. labour is what those hours cost, at the wage of every till and the
+// store's wage for the attendant.
+func labourHours(sim *simulation, eStore *store) (hours float64, labour float64) {
+	start, end := storeWindow(sim, eStore)
+	for _, kCheckout := range sortedCheckoutKeys(eStore) {
+		eCheckout := eStore.checkouts[kCheckout]
+		if !eCheckout.selfService {
+			staffed := eCheckout.staffedSeconds(start, end) / 3600
+			hours += staffed
+			labour += staffed * eCheckout.hourlyWage
+		}
+	}
+	if eStore.selfCheckout.kiosks > 0 {
+		hours += (end - start) / 3600
+		labour += (end - start) / 3600 * eStore.economics.hourlyWage
+	}
+	return hours, labour
+}
+
+func collectEconomics(sim *simulation, eStore *store) dayEconomics {
+	var day dayEconomics
+	for _, eCustomer := range sortedCustomers(eStore) {
+		switch eCustomer.outcome {
+		case outcomeServed:
+			day.revenue += eCustomer.basketPrice
+		case outcomeReneged, outcomeBalked, outcomePaymentFailed:
+			day.lostRevenue += eCustomer.basketPrice
+		}
+	}
+	_, day.labour = labourHours(sim, eStore)
+	day.profit = day.revenue*eStore.economics.grossMargin - day.labour
+	return day
+}
+
+// printEconomics is the money lines of the end of day summary.
+func printEconomics(out io.Writer, kStore string, eStore *store) {
+	day := collectEconomics(eStore.sim, eStore)
+	hours, _ := labourHours(eStore.sim, eStore)
+	fmt.Fprintf(out, "---Store: %s, Revenue: %.2f, Lost revenue (left the line or did not join): %.2f\n",
+		kStore, day.revenue, day.lostRevenue)
+	fmt.Fprintf(out, "---Store: %s, Checkout labour: %.2f (%.1f hours), Gross margin: %.2f, Profit after checkout labour: %.2f\n",
+		kStore, day.labour, hours, day.revenue*eStore.economics.grossMargin, day.profit)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+)
+
+// simEvent is something that will happen at a given simulated second.
+// Events at the same second fire in the order they were scheduled (seq),
+// that is what keeps two runs of the same day identical.
+type simEvent struct {
+	at        float64
+	seq       int64
+	fire      func()
+	cancelled bool
+	index     int
+}
+
+// eventQueue is a min-heap of events ordered by time and then by seq.
+type eventQueue []*simEvent
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at == q[j].at {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at < q[j].at
+}
+func (q eventQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *eventQueue) Push(x interface{}) {
+	ev := x.(*simEvent)
+	ev.index = len(*q)
+	*q = append(*q, ev)
+}
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	ev := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return ev
+}
+
+// simProcess is a goroutine driven by the engine (openCheckout, customerSpawning...).
+// Only one process runs at a time, the engine hands over control through resume
+// and waits on yield until the process sleeps, waits on a queue or finishes.
+type simProcess struct {
+	name   string
+	resume chan bool
+	done   bool
+}
+
+// simEngine is the discrete-event kernel. It keeps the virtual clock and a priority
+// queue of scheduled events. In real time mode it sleeps between events, so a live
+// demo looks like it did before, otherwise it jumps straight to the next event.
+type simEngine struct {
+	now       float64
+	seq       int64
+	events    eventQueue
+	clock     *dualTimeClock
+	realTime  bool
+	current   *simProcess
+	processes []*simProcess
+	yield     chan struct{}
+	failure   error
+}
+
+func newSimEngine(clock *dualTimeClock, realTime bool) *simEngine {
+	return &simEngine{
+		now:      float64(clock.simWorldStartTime),
+		clock:    clock,
+		realTime: realTime,
+		yield:    make(chan struct{}),
+	}
+}
+
+// schedule runs fire after delay simulated seconds.
+func (e *simEngine) schedule(delay float64, fire func()) *simEvent {
+	if delay < 0 {
+		delay = 0
+	}
+	e.seq++
+	ev := &simEvent{at: e.now + delay, seq: e.seq, fire: fire}
+	heap.Push(&e.events, ev)
+	return ev
+}
+
+// cancel stops a scheduled event from firing, it is fine to cancel an event twice.
+func (e *simEngine) cancel(ev *simEvent) {
+	if ev != nil {
+		ev.cancelled = true
+	}
+}
+
+// spawn starts body as a process at the current simulated time.
+func (e *simEngine) spawn(name string, body func()) {
+	p := &simProcess{name: name, resume: make(chan bool)}
+	e.processes = append(e.processes, p)
+	go func() {
+		if !<-p.resume {
+			return
+		}
+		defer func() {
+			// A panic in a process would take the whole program down from its own
+			// goroutine, so hand it back to the engine as a failed run instead.
+			if r := recover(); r != nil {
+				e.failure = fmt.Errorf("%s: %v", p.name, r)
+				p.done = true
+				e.yield <- struct{}{}
+			}
+		}()
+		body()
+		p.done = true
+		e.yield <- struct{}{}
+	}()
+	e.schedule(0, func() { e.activate(p) })
+}
+
+// activate gives control to p and blocks until p hands it back.
+func (e *simEngine) activate(p *simProcess) {
+	e.current = p
+	p.resume <- true
+	<-e.yield
+	e.current = nil
+}
+
+// passivate hands control back to the engine from inside the running process and
+// blocks until somebody activates it again. If the run is over the goroutine exits.
+func (e *simEngine) passivate() {
+	p := e.current
+	e.yield <- struct{}{}
+	if !<-p.resume {
+		runtime.Goexit()
+	}
+}
+
+// sleep must be called from inside a process, it replaces time.Sleep.
+func (e *simEngine) sleep(seconds float64) {
+	p := e.current
+	e.schedule(seconds, func() { e.activate(p) })
+	e.passivate()
+}
+
+// run fires events in time order until there is nothing left to do, ctx is
+// cancelled (the day is over, or the user pressed Ctrl+C) or one of the processes fails.
+func (e *simEngine) run(ctx context.Context) error {
+	for e.events.Len() > 0 && e.failure == nil && ctx.Err() == nil {
+		ev := heap.Pop(&e.events).(*simEvent)
+		if ev.cancelled {
+			continue
+		}
+		if e.realTime && ev.at > e.now {
+			e.clock.sleepUntilSimTime(ev.at)
+		}
+		e.now = ev.at
+		e.clock.simWorldCurrentTime = int64(e.now)
+		ev.fire()
+	}
+	e.shutdown()
+	return e.failure
+}
+
+// shutdown releases every process still waiting, so no goroutine outlives the run.
+func (e *simEngine) shutdown() {
+	for _, p := range e.processes {
+		if !p.done {
+			p.done = true
+			p.resume <- false
+		}
+	}
+}
+
+// simQueue is a FIFO line of customers in front of one or more checkouts.
+// It takes the place of the unbuffered channels, so the spawner never blocks.
+type simQueue struct {
+	engine  *simEngine
+	items   []*customer
+	waiting []*queueWaiter
+	closed  bool
+
+	// For the report: the area under the queue length over time gives the mean length.
+	since      float64
+	lastChange float64
+	area       float64
+	maxLength  int
+}
+
+// queueWaiter is a process waiting for a customer. woken makes sure it is only woken
+// once, by a customer arriving, the line closing, its wait timing out or an interrupt.
+type queueWaiter struct {
+	process     *simProcess
+	woken       bool
+	interrupted bool
+}
+
+func (q *simQueue) wake(w *queueWaiter) {
+	w.woken = true
+	q.engine.schedule(0, func() { q.engine.activate(w.process) })
+}
+
+func newSimQueue(engine *simEngine) *simQueue {
+	return &simQueue{engine: engine, since: engine.now, lastChange: engine.now}
+}
+
+// changing has to be called before the number of customers in the line changes.
+func (q *simQueue) changing() {
+	q.area += float64(len(q.items)) * (q.engine.now - q.lastChange)
+	q.lastChange = q.engine.now
+}
+
+// put adds a customer to the back of the line and wakes up a checkout if one is waiting.
+func (q *simQueue) put(c *customer) {
+	q.putAt(len(q.items), c)
+}
+
+// putAt is put, but the customer goes in at position at instead of the back.
+func (q *simQueue) putAt(at int, c *customer) {
+	q.changing()
+	q.items = append(q.items, nil)
+	copy(q.items[at+1:], q.items[at:])
+	q.items[at] = c
+	if len(q.items) > q.maxLength {
+		q.maxLength = len(q.items)
+	}
+	if len(q.waiting) > 0 {
+		w := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.wake(w)
+	}
+}
+
+// interrupt makes everybody waiting for a customer give up, get and getUntil return nil.
+func (q *simQueue) interrupt() {
+	for _, w := range q.waiting {
+		w.interrupted = true
+		q.wake(w)
+	}
+	q.waiting = nil
+}
+
+// get takes the customer at the front, waiting in simulated time while the line is empty.
+// Once the line is closed and empty it returns nil.
+func (q *simQueue) get() *customer {
+	return q.getUntil(math.Inf(1))
+}
+
+// getUntil is get, giving up and returning nil if nobody turned up by deadline.
+func (q *simQueue) getUntil(deadline float64) *customer {
+	for len(q.items) == 0 {
+		if q.closed || q.engine.now >= deadline {
+			return nil
+		}
+		w := &queueWaiter{process: q.engine.current}
+		q.waiting = append(q.waiting, w)
+		var timeout *simEvent
+		if !math.IsInf(deadline, 1) {
+			timeout = q.engine.schedule(deadline-q.engine.now, func() {
+				if w.woken {
+					return
+				}
+				for i, other := range q.waiting {
+					if other == w {
+						q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+						break
+					}
+				}
+				q.wake(w)
+			})
+		}
+		q.engine.passivate()
+		q.engine.cancel(timeout)
+		if w.interrupted {
+			return nil
+		}
+	}
+	q.changing()
+	c := q.items[0]
+	q.items = q.items[1:]
+	return c
+}
+
+// close stops new customers joining and wakes every waiting checkout, so those with
+// nobody left in the line can shut.
+func (q *simQueue) close() {
+	q.closed = true
+	for _, w := range q.waiting {
+		q.wake(w)
+	}
+	q.waiting = nil
+}
+
+// remove takes c out of the line wherever it stands, it returns false if c was not in it.
+func (q *simQueue) remove(c *customer) bool {
+	for i, eCustomer := range q.items {
+		if eCustomer == c {
+			q.changing()
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// drain takes everybody out of the line at once, front first.
+func (q *simQueue) drain() []*customer {
+	q.changing()
+	drained := q.items
+	q.items = nil
+	return drained
+}
+
+// len is the number of customers standing in the line.
+func (q *simQueue) len() int {
+	return len(q.items)
+}
+
+// meanLength is the time weighted average length of the line up to end.
+func (q *simQueue) meanLength(end float64) float64 {
+	if end <= q.since {
+		return 0
+	}
+	area := q.area + float64(len(q.items))*(end-q.lastChange)
+	return area / (end - q.since)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestEngineFiresInTimeThenScheduleOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		delays []float64
+		want   []int
+	}{
+		{"in time order", []float64{3, 1, 2}, []int{1, 2, 0}},
+		{"same time in schedule order", []float64{5, 5, 5, 5}, []int{0, 1, 2, 3}},
+		{"ties between earlier events", []float64{2, 1, 2, 1, 0}, []int{4, 1, 3, 0, 2}},
+		{"negative delay is now", []float64{1, -5, 0}, []int{1, 2, 0}},
+	}
+	for _, test := range tests {
+		engine := newSimEngine(&dualTimeClock{}, false)
+		var fired []int
+		var at []float64
+		for i, delay := range test.delays {
+			engine.schedule(delay, func() {
+				fired = append(fired, i)
+				at = append(at, engine.now)
+			})
+		}
+		if err := engine.run(context.Background()); err != nil {
+			t.Fatalf("%s: run failed: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(fired, test.want) {
+			t.Errorf("%s: fired %v, want %v", test.name, fired, test.want)
+		}
+		for i := 1; i < len(at); i++ {
+			if at[i] < at[i-1] {
+				t.Errorf("%s: the clock went back from %g to %g", test.name, at[i-1], at[i])
+			}
+		}
+	}
+}
+
+func TestEngineEventsScheduledWhileFiring(t *testing.T) {
+	engine := newSimEngine(&dualTimeClock{}, false)
+	var fired []string
+	engine.schedule(1, func() {
+		fired = append(fired, "a")
+		// Scheduled now for now, it still comes after b, which was scheduled first.
+		engine.schedule(0, func() { fired = append(fired, "c") })
+	})
+	engine.schedule(1, func() { fired = append(fired, "b") })
+	cancelled := engine.schedule(1, func() { fired = append(fired, "cancelled") })
+	engine.cancel(cancelled)
+	engine.cancel(cancelled)
+
+	if err := engine.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(fired, want) {
+		t.Errorf("fired %v, want %v", fired, want)
+	}
+}
+
+func TestSimQueueGetUntil(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline float64
+		// event happens to the line after that many seconds, put, close or interrupt.
+		event   string
+		eventAt float64
+		wantGot bool
+		wantAt  float64
+	}{
+		{"customer before the deadline", 10, "put", 4, true, 4},
+		{"customer after the deadline", 10, "put", 15, false, 10},
+		// The put was scheduled before the wait started, so it fires before the timeout.
+		{"customer at the deadline", 10, "put", 10, true, 10},
+		{"nobody comes", 10, "", 0, false, 10},
+		{"deadline already passed", 0, "put", 5, false, 0},
+		{"no deadline", -1, "put", 25, true, 25},
+		{"line closed", 10, "close", 3, false, 3},
+		{"interrupted", 10, "interrupt", 6, false, 6},
+	}
+	for _, test := range tests {
+		engine := newSimEngine(&dualTimeClock{}, false)
+		queue := newSimQueue(engine)
+		waiting := &customer{customerId: 1}
+
+		var got *customer
+		gotAt := -1.0
+		engine.spawn("till", func() {
+			if test.deadline < 0 {
+				got = queue.get()
+			} else {
+				got = queue.getUntil(engine.now + test.deadline)
+			}
+			gotAt = engine.now
+		})
+		switch test.event {
+		case "put":
+			engine.schedule(test.eventAt, func() { queue.put(waiting) })
+		case "close":
+			engine.schedule(test.eventAt, queue.close)
+		case "interrupt":
+			engine.schedule(test.eventAt, queue.interrupt)
+		}
+
+		if err := engine.run(context.Background()); err != nil {
+			t.Fatalf("%s: run failed: %v", test.name, err)
+		}
+		if (got != nil) != test.wantGot {
+			t.Errorf("%s: got a customer = %t, want %t", test.name, got != nil, test.wantGot)
+		}
+		if gotAt != test.wantAt {
+			t.Errorf("%s: getUntil returned at %g, want %g", test.name, gotAt, test.wantAt)
+		}
+		if len(queue.waiting) != 0 {
+			t.Errorf("%s: %d still waiting on the line", test.name, len(queue.waiting))
+		}
+	}
+}
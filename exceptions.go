@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// The things that can hold up scanning at a staffed checkout.
+const (
+	exceptionIdCheck       = "id-check"
+	exceptionPriceOverride = "price-override"
+	exceptionVoid          = "void"
+	exceptionManualKeyIn   = "manual-key-in"
+)
+
+// exceptionKinds in the order they are asked for and reported.
+var exceptionKinds = []string{exceptionIdCheck, exceptionPriceOverride, exceptionVoid, exceptionManualKeyIn}
+
+// exceptionDefaults are the seconds every exception takes, and if the cashier has to call
+// the supervisor for it. An ID check and keying in a barcode the cashier does on their own.
+var exceptionDefaults = map[string]struct {
+	seconds    string
+	supervisor bool
+}{
+	exceptionIdCheck:       {"10-30", false},
+	exceptionPriceOverride: {"30-90", true},
+	exceptionVoid:          {"20-60", true},
+	exceptionManualKeyIn:   {"5-15", false},
+}
+
+// exceptionRule is how likely an exception is and how long it takes. The chance of an ID
+// check is per customer (with a catalogue, per customer buying an age-restricted item),
+// the others are per item.
+type exceptionRule struct {
+	chance     float64
+	time       floatRange
+	supervisor bool
+}
+
+// exceptionTally is what the exceptions of one kind cost a checkout. lostSeconds includes
+// waiting for the supervisor.
+type exceptionTally struct {
+	count         int
+	lostSeconds   float64
+	waitedSeconds float64
+}
+
+func readExceptionSettings(iStore int, defaultSettingsCode string) (map[string]exceptionRule, error) {
+	rules := map[string]exceptionRule{}
+	prefix := "[Store " + strconv.Itoa(iStore) + "] "
+	code := "[store" + strconv.Itoa(iStore) + "]"
+
+	for _, kind := range exceptionKinds {
+		per := "item"
+		if kind == exceptionIdCheck {
+			per = "customer"
+		}
+		rule := exceptionRule{supervisor: exceptionDefaults[kind].supervisor}
+		var err error
+		rule.chance, err = readFloat(
+			prefix+"How likely is a "+kind+" at a staffed checkout, per "+per+"? From 0 to 1 [0] ",
+			"0",
+			defaultSettingsCode,
+			code+"exceptionChance_"+kind,
+			0, 1)
+		if err != nil {
+			return rules, err
+		}
+		if rule.chance > 0 {
+			rule.time, err = readFloatRange(
+				prefix+"How many seconds does a "+kind+" take? ["+exceptionDefaults[kind].seconds+"] ",
+				exceptionDefaults[kind].seconds,
+				defaultSettingsCode,
+				code+"exceptionTime_"+kind,
+				0, 3600)
+			if err != nil {
+				return rules, err
+			}
+		}
+		rules[kind] = rule
+	}
+	return rules, nil
+}
+
+// handleException holds the checkout up for one exception, with the supervisor if it needs
+// them. The supervisor is one person for the whole store, so tills can end up waiting on
+// each other.
+func handleException(store *store, checkout *checkout, customer *customer, kind string) {
+	sim := store.sim
+	rule := store.exceptions[kind]
+	seconds := drawSeconds(customer.rng.exceptions, rule.time)
+	started := sim.engine.now
+
+	if rule.supervisor {
+		sim.logf("Checkout %2d: %s for customer %4d, calling the supervisor\n", checkout.checkoutId, kind, customer.customerId)
+		store.supervisor.call(sim, kind, seconds)
+	} else {
+		sim.logf("Checkout %2d: %s for customer %4d\n", checkout.checkoutId, kind, customer.customerId)
+		sim.sleep(seconds)
+	}
+
+	if checkout.exceptions == nil {
+		checkout.exceptions = map[string]*exceptionTally{}
+	}
+	if checkout.exceptions[kind] == nil {
+		checkout.exceptions[kind] = &exceptionTally{}
+	}
+	tally := checkout.exceptions[kind]
+	tally.count++
+	tally.lostSeconds += sim.engine.now - started
+	tally.waitedSeconds += sim.engine.now - started - seconds
+}
+
+// checkId is the cashier asking for ID before scanning, if the customer is asked.
+func checkId(store *store, checkout *checkout, customer *customer) {
+	rule := store.exceptions[exceptionIdCheck]
+	if rule.chance == 0 || (store.catalogue != nil && !customer.hasAgeRestrictedItem()) {
+		return
+	}
+	if customer.rng.exceptions.Float64() < rule.chance {
+		handleException(store, checkout, customer, exceptionIdCheck)
+	}
+}
+
+// scanExceptions are the things that can go wrong with an item once it is scanned.
+func scanExceptions(store *store, checkout *checkout, customer *customer) {
+	for _, kind := range exceptionKinds[1:] {
+		rule := store.exceptions[kind]
+		if rule.chance > 0 && customer.rng.exceptions.Float64() < rule.chance {
+			handleException(store, checkout, customer, kind)
+		}
+	}
+}
+
+// hasExceptions tells if any exception can happen in the store.
+func hasExceptions(store *store) bool {
+	for _, rule := range store.exceptions {
+		if rule.chance > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// printExceptionReport shows the time every checkout lost to exceptions and how busy the
+// supervisor was.
+func printExceptionReport(out io.Writer, sim *simulation, kStore string) {
+	eStore := sim.stores[kStore]
+	if !hasExceptions(eStore) {
+		return
+	}
+	start, end := storeWindow(sim, eStore)
+
+	fmt.Fprintf(out, "%-12s", "Exceptions")
+	for _, kind := range exceptionKinds {
+		fmt.Fprintf(out, " %14s", kind)
+	}
+	fmt.Fprintf(out, " %9s %11s\n", "Lost(min)", "Waited(min)")
+	for _, kCheckout := range sortedCheckoutKeys(eStore) {
+		eCheckout := eStore.checkouts[kCheckout]
+		if eCheckout.selfService {
+			continue
+		}
+		lost, waited := 0.0, 0.0
+		fmt.Fprintf(out, "%-12s", eCheckout.label())
+		for _, kind := range exceptionKinds {
+			tally := eCheckout.exceptions[kind]
+			if tally == nil {
+				tally = &exceptionTally{}
+			}
+			fmt.Fprintf(out, " %14d", tally.count)
+			lost += tally.lostSeconds
+			waited += tally.waitedSeconds
+		}
+		fmt.Fprintf(out, " %9.1f %11.1f\n", lost/60, waited/60)
+	}
+
+	supervisor := eStore.supervisor
+	meanWait := 0.0
+	if supervisor.totalCalls() > 0 {
+		meanWait = supervisor.waitSeconds / float64(supervisor.totalCalls())
+	}
+	fmt.Fprintf(out, "Supervisor: %d calls, busy %.1f%%, mean wait for the supervisor %.0f s\n",
+		supervisor.totalCalls(), 100*supervisor.busySeconds/(end-start), meanWait)
+}
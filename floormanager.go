@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// floorManagerRoundSeconds is how often the floor manager walks past the checkouts.
+const floorManagerRoundSeconds = 60
+
+// floorManagerSettings are the rules the floor manager works by. The reserve staff each
+// have a standby till they open when the lines have been long for ruleMinutes and close
+// again when it has been quiet for as long.
+type floorManagerSettings struct {
+	reserveStaff int
+	// openQueueLength is the people waiting per open till, in every line, that is busy.
+	openQueueLength int
+	// openWaitMinutes is the longest anybody should have been in a line.
+	openWaitMinutes int
+	// closeQueueLength is the people waiting per open till, in every line, that is quiet.
+	closeQueueLength int
+	ruleMinutes      int
+	// redirectMaxItems sends customers with that many items or fewer to an express lane
+	// when its line is shorter, 0 leaves them be.
+	redirectMaxItems int
+}
+
+// managerDecision is one entry of the floor manager's log.
+type managerDecision struct {
+	at         float64
+	action     string
+	checkoutId int
+	reason     string
+}
+
+func readFloorManagerSettings(iStore int, defaultSettingsCode string) (floorManagerSettings, error) {
+	var settings floorManagerSettings
+	prefix := "[Store " + strconv.Itoa(iStore) + "] "
+	code := "[store" + strconv.Itoa(iStore) + "]"
+
+	var err error
+	settings.reserveStaff, err = readInt(
+		prefix+"How many reserve staff can the floor manager put on a standby till? 0 means none [0] ",
+		"0",
+		defaultSettingsCode,
+		code+"reserveStaff",
+		0, 100)
+	if err != nil {
+		return settings, err
+	}
+	if settings.reserveStaff > 0 {
+		settings.openQueueLength, err = readInt(
+			prefix+"Open a standby till with more than how many people waiting for every open till? [3] ",
+			"3",
+			defaultSettingsCode,
+			code+"openQueueLength",
+			0, 1000)
+		if err != nil {
+			return settings, err
+		}
+		settings.openWaitMinutes, err = readInt(
+			prefix+"Or when somebody has been in a line for more than how many minutes? 0 means never [10] ",
+			"10",
+			defaultSettingsCode,
+			code+"openWaitMinutes",
+			0, 600)
+		if err != nil {
+			return settings, err
+		}
+		settings.closeQueueLength, err = readInt(
+			prefix+"Close a standby till with at most how many people waiting for every open till? [0] ",
+			"0",
+			defaultSettingsCode,
+			code+"closeQueueLength",
+			0, 1000)
+		if err != nil {
+			return settings, err
+		}
+		settings.ruleMinutes, err = readInt(
+			prefix+"For how many minutes do the lines have to be busy or quiet before the floor manager acts? [5] ",
+			"5",
+			defaultSettingsCode,
+			code+"ruleMinutes",
+			0, 600)
+		if err != nil {
+			return settings, err
+		}
+	}
+	settings.redirectMaxItems, err = readInt(
+		prefix+"Send customers with at most how many items to an express lane? 0 means no [0] ",
+		"0",
+		defaultSettingsCode,
+		code+"redirectMaxItems",
+		0, 10000)
+	return settings, err
+}
+
+// addStandbyTills puts a till for every member of the reserve staff next to the staffed
+// checkouts. Nobody is rostered on them, they stay closed until the floor manager opens them.
+func addStandbyTills(checkouts map[string]*checkout, settings floorManagerSettings) {
+	firstId := len(checkouts) + 1
+	for iStandby := 0; iStandby < settings.reserveStaff; iStandby++ {
+		checkouts["checkout"+strconv.Itoa(firstId+iStandby)] = &checkout{
+			checkoutId:           firstId + iStandby,
+			standby:              true,
+			cashierEfficiency:    1,
+			checkoutDesirability: firstId + iStandby,
+			currentDeep:          SafeCounter{v: 0},
+			status:               "IDLE",
+			totalItemsCheckedOut: SafeCounter{v: 0},
+			totalCustomersServed: SafeCounter{v: 0},
+		}
+	}
+}
+
+func (store *store) decide(action string, checkout *checkout, reason string) {
+	sim := store.sim
+	store.decisions = append(store.decisions, managerDecision{
+		at: sim.engine.now, action: action, checkoutId: checkout.checkoutId, reason: reason})
+	sim.logf("Floor manager: %s Checkout %2d, %s\n", action, checkout.checkoutId, reason)
+}
+
+// lineLoad is the most and the fewest people waiting per open till over the lines of a
+// store, and the longest anybody has been waiting, in seconds. Express lanes are short by
+// design, only the lines of the tills without an item limit count.
+func lineLoad(store *store) (most float64, fewest float64, longestWait float64) {
+	sim := store.sim
+	tills := map[*simQueue]int{}
+	for _, kCheckout := range sortedCheckoutKeys(store) {
+		eCheckout := store.checkouts[kCheckout]
+		if !eCheckout.selfService && eCheckout.itemLimit(sim.engine.now) == 0 && eCheckout.accepting {
+			tills[sim.queues[getQueueIndex(store, eCheckout)]]++
+		}
+	}
+
+	fewest = math.Inf(1)
+	for _, queue := range storeQueues(sim, store) {
+		if tills[queue] == 0 {
+			continue
+		}
+		perTill := float64(queue.len()) / float64(tills[queue])
+		most = math.Max(most, perTill)
+		fewest = math.Min(fewest, perTill)
+		for _, eCustomer := range queue.items {
+			longestWait = math.Max(longestWait, sim.engine.now-float64(eCustomer.queueTimeStart))
+		}
+	}
+	return most, fewest, longestWait
+}
+
+// floorManager is the floor manager's day: every round they look at the lines and open a
+// standby till once they have been busy for ruleMinutes, or close the last one opened once
+// it has been quiet for as long. At closing time the tills that are open stay open.
+func floorManager(store *store) {
+	sim := store.sim
+	settings := store.floorManager
+	busySince, quietSince := -1.0, -1.0
+	ruleSeconds := float64(settings.ruleMinutes * 60)
+
+	for !store.doorsClosed {
+		sim.sleep(floorManagerRoundSeconds)
+		if store.doorsClosed {
+			return
+		}
+
+		most, fewest, longestWait := lineLoad(store)
+		longLines := fewest > float64(settings.openQueueLength) && !math.IsInf(fewest, 1)
+		longWait := settings.openWaitMinutes > 0 && longestWait > float64(settings.openWaitMinutes*60)
+		busy := longLines || longWait
+		quiet := most <= float64(settings.closeQueueLength)
+
+		switch {
+		case !busy:
+			busySince = -1
+		case busySince < 0:
+			busySince = sim.engine.now
+		}
+		switch {
+		case !quiet:
+			quietSince = -1
+		case quietSince < 0:
+			quietSince = sim.engine.now
+		}
+
+		if busySince >= 0 && sim.engine.now-busySince >= ruleSeconds {
+			if till := standbyTill(store, false); till != nil {
+				reason := fmt.Sprintf("at least %.1f waiting per till in every line", fewest)
+				if !longLines {
+					reason = fmt.Sprintf("somebody has waited %.1f min", minutes(longestWait))
+				}
+				openStandbyTill(store, till, reason)
+			}
+			busySince = -1
+		}
+		if quietSince >= 0 && sim.engine.now-quietSince >= ruleSeconds {
+			if till := standbyTill(store, true); till != nil {
+				closeStandbyTill(store, till, fmt.Sprintf("at most %.1f waiting per till", most))
+			}
+			quietSince = -1
+		}
+	}
+}
+
+// standbyTill is the last standby till that is open when isOpen, the one to close, and
+// otherwise the first one that is closed, the one to open.
+func standbyTill(store *store, isOpen bool) *checkout {
+	var found *checkout
+	for _, kCheckout := range sortedCheckoutKeys(store) {
+		eCheckout := store.checkouts[kCheckout]
+		if !eCheckout.standby {
+			continue
+		}
+		if isOpen && eCheckout.running && eCheckout.accepting {
+			found = eCheckout
+		}
+		if !isOpen && !eCheckout.running {
+			return eCheckout
+		}
+	}
+	return found
+}
+
+// openStandbyTill rosters the reserve cashier on the till from now until told otherwise.
+func openStandbyTill(store *store, till *checkout, reason string) {
+	sim := store.sim
+	till.roster = append(till.roster, shift{
+		cashier:    "RESERVE" + strconv.Itoa(till.checkoutId),
+		efficiency: till.cashierEfficiency,
+		from:       sim.engine.now,
+		to:         math.Inf(1),
+	})
+	store.decide("open", till, reason)
+	spawnCheckout(store, "checkout"+strconv.Itoa(till.checkoutId), till)
+}
+
+// closeStandbyTill ends the reserve cashier's shift now. The till finishes the customer
+// it is serving, its line moves over and it shuts, see roster.go.
+func closeStandbyTill(store *store, till *checkout, reason string) {
+	sim := store.sim
+	till.roster[len(till.roster)-1].to = sim.engine.now
+	store.decide("close", till, reason)
+	closeTill(store, till)
+	sim.queues[getQueueIndex(store, till)].interrupt()
+}
+
+// redirectToExpress is the floor manager sending a customer with a small basket to the
+// express lane with the fewest people, if that is fewer than where they were going.
+func redirectToExpress(store *store, customer *customer, chosen *checkout) *checkout {
+	limit := store.floorManager.redirectMaxItems
+	if chosen == nil || !store.hasFloorManager || limit == 0 || customer.items > limit || chosen.selfService ||
+		chosen.itemLimit(store.sim.engine.now) > 0 {
+		return chosen
+	}
+	var express []*checkout
+	for _, kCheckout := range sortedCheckoutKeys(store) {
+		eCheckout := store.checkouts[kCheckout]
+		if !eCheckout.selfService && eCheckout.itemLimit(store.sim.engine.now) > 0 &&
+			canUseCheckout(store, eCheckout, customer) {
+			express = append(express, eCheckout)
+		}
+	}
+	target := pickLowest(express, func(c *checkout) float64 {
+		return float64(customersWaiting(store, c))
+	})
+	if target == nil || customersWaiting(store, target) >= customersWaiting(store, chosen) {
+		return chosen
+	}
+	store.decide("redirect to", target, fmt.Sprintf("customer %d with %d items instead of Checkout %d",
+		customer.customerId, customer.items, chosen.checkoutId))
+	customer.routingPolicy = "floor-manager"
+	return target
+}
+
+// queuingSeconds is the time every customer of the store spent in a line, served or not.
+func queuingSeconds(store *store) float64 {
+	total := 0.0
+	for _, eCustomer := range sortedCustomers(store) {
+		total += float64(eCustomer.queueTimeSeconds)
+	}
+	return total
+}
+
+// hasFloorManagerActions tells if a floor manager in any of the stores does more than route.
+func hasFloorManagerActions(sim *simulation) bool {
+	for _, eStore := range sim.stores {
+		if eStore.hasFloorManager && (eStore.floorManager.reserveStaff > 0 || eStore.floorManager.redirectMaxItems > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// printFloorManagerReport is the floor manager's decision log and the staff-minutes the
+// reserve staff worked against the queuing time saved, compared with the same day where
+// the floor manager neither opens tills nor redirects anybody (baseline).
+func printFloorManagerReport(out io.Writer, sim *simulation, baseline *simulation) {
+	for _, kStore := range sortedStoreKeys(sim.stores) {
+		eStore := sim.stores[kStore]
+		if !eStore.hasFloorManager || (eStore.floorManager.reserveStaff == 0 && eStore.floorManager.redirectMaxItems == 0) {
+			continue
+		}
+		start, end := storeWindow(sim, eStore)
+
+		fmt.Fprintf(out, "===Store: %s floor manager\n", kStore)
+		counts := map[string]int{}
+		for _, decision := range eStore.decisions {
+			counts[decision.action]++
+			fmt.Fprintf(out, "%s %-11s Checkout %2d, %s\n",
+				formatSimTime(int64(decision.at)), decision.action, decision.checkoutId, decision.reason)
+		}
+
+		staffSeconds := 0.0
+		for _, eCheckout := range eStore.checkouts {
+			if eCheckout.standby {
+				staffSeconds += eCheckout.staffedSeconds(start, end)
+			}
+		}
+		with := collectStatistics(sim, kStore)
+		without := collectStatistics(baseline, kStore)
+		saved := queuingSeconds(baseline.stores[kStore]) - queuingSeconds(eStore)
+		perStaffMinute := 0.0
+		if staffSeconds > 0 {
+			perStaffMinute = saved / staffSeconds
+		}
+
+		fmt.Fprintf(out, "Decisions: %d opened, %d closed, %d redirected to express\n",
+			counts["open"], counts["close"], counts["redirect to"])
+		fmt.Fprintf(out, "Reserve staff: %.0f staff-minutes, queuing time saved %.0f customer-minutes (%.1f per staff-minute)\n",
+			staffSeconds/60, saved/60, perStaffMinute)
+		fmt.Fprintf(out, "Served %d, abandoned %d with the floor manager, served %d, abandoned %d without\n",
+			with.served, with.reneged+with.balked, without.served, without.reneged+without.balked)
+	}
+}
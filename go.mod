@@ -0,0 +1,3 @@
+module cs5741
+
+go 1.22
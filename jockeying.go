@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// neighbourCheckouts are the checkouts either side of target, the ones a customer can
+// see well enough from their line to notice it got shorter.
+func neighbourCheckouts(store *store, target *checkout) []*checkout {
+	var neighbours []*checkout
+	for _, kCheckout := range sortedCheckoutKeys(store) {
+		eCheckout := store.checkouts[kCheckout]
+		if eCheckout.selfService {
+			continue
+		}
+		if eCheckout.checkoutId == target.checkoutId-1 || eCheckout.checkoutId == target.checkoutId+1 {
+			neighbours = append(neighbours, eCheckout)
+		}
+	}
+	return neighbours
+}
+
+// lineGotShorter is called whenever a line gets shorter: a customer is done at the till,
+// gives up, moves away, or a till opens. The customers waiting in the lines either side
+// see it and each decides for themselves if they switch, see considerSwitching. A switch
+// makes another line shorter in turn, those lines are looked at after this one.
+func lineGotShorter(store *store, changed *checkout) {
+	if !store.jockeying || changed.selfService {
+		return
+	}
+	store.shorterLines = append(store.shorterLines, changed)
+	if store.lookingAround {
+		return
+	}
+	store.lookingAround = true
+	defer func() { store.lookingAround = false }()
+
+	sim := store.sim
+	for len(store.shorterLines) > 0 {
+		changed := store.shorterLines[0]
+		store.shorterLines = store.shorterLines[1:]
+		changedQueue := sim.queues[getQueueIndex(store, changed)]
+		for _, neighbour := range neighbourCheckouts(store, changed) {
+			queue := sim.queues[getQueueIndex(store, neighbour)]
+			if queue == changedQueue {
+				// Same shared line, there is nowhere to move to.
+				continue
+			}
+			// From the back of the line, those are the ones with the most to win.
+			waiting := append([]*customer(nil), queue.items...)
+			for i := len(waiting) - 1; i >= 0; i-- {
+				considerSwitching(store, waiting[i], neighbour)
+			}
+		}
+	}
+}
+
+// considerSwitching is a customer waiting in current's line looking at the lines either
+// side. They move to the one that puts them furthest forward if that is at least
+// jockeyThreshold people, the basket is allowed there and they feel like it.
+func considerSwitching(store *store, eCustomer *customer, current *checkout) {
+	sim := store.sim
+	queue := sim.queues[getQueueIndex(store, current)]
+	ahead := -1
+	for i, waiting := range queue.items {
+		if waiting == eCustomer {
+			ahead = i
+			break
+		}
+	}
+	if ahead < 0 {
+		// Served, gone or moved since.
+		return
+	}
+	if current.serving != nil {
+		ahead++
+	}
+
+	var best *checkout
+	bestGain := 0
+	for _, neighbour := range neighbourCheckouts(store, current) {
+		if sim.queues[getQueueIndex(store, neighbour)] == queue || !canUseCheckout(store, neighbour, eCustomer) {
+			continue
+		}
+		gain := ahead - customersWaiting(store, neighbour)
+		if gain >= store.jockeyThreshold && gain > bestGain {
+			best, bestGain = neighbour, gain
+		}
+	}
+	if best == nil || store.rng.jockeying.Float64() >= eCustomer.jockeyPropensity {
+		return
+	}
+	switchQueue(store, eCustomer, current, best)
+}
+
+// switchQueue is the customer deciding to move to another line.
+func switchQueue(store *store, eCustomer *customer, from *checkout, to *checkout) {
+	if !moveCustomer(store, eCustomer, from, to) {
+		return
+	}
+	eCustomer.switches++
+	store.sim.logf("Customer %4d moves from Checkout %2d to Checkout %2d\n",
+		eCustomer.customerId, from.checkoutId, to.checkoutId)
+}
+
+// moveCustomer moves a waiting customer to the back of another line. Their wait and
+// patience keep counting from when they first joined a line.
+func moveCustomer(store *store, eCustomer *customer, from *checkout, to *checkout) bool {
+	sim := store.sim
+	if !sim.queues[getQueueIndex(store, from)].remove(eCustomer) {
+		return false
+	}
+	sim.engine.cancel(eCustomer.renegeEvent)
+	from.currentDeep.Dec()
+
+	eCustomer.checkoutId = to.checkoutId
+	to.currentDeep.Inc()
+	joinLine(store, to, eCustomer)
+	scheduleRenege(store, to, eCustomer)
+	lineGotShorter(store, from)
+	return true
+}
+
+// hasJockeying tells if customers switch lines in any of the stores.
+func hasJockeying(sim *simulation) bool {
+	for _, eStore := range sim.stores {
+		if eStore.jockeying {
+			return true
+		}
+	}
+	return false
+}
+
+// printJockeyingReport compares the day with the same day where nobody switches lines
+// (baseline, same seed so the same customers arrive at the same time with the same baskets).
+func printJockeyingReport(out io.Writer, sim *simulation, baseline *simulation) {
+	for _, kStore := range sortedStoreKeys(sim.stores) {
+		eStore := sim.stores[kStore]
+		if !eStore.jockeying {
+			continue
+		}
+
+		switches, switchers, mostSwitches := 0, 0, 0
+		for _, eCustomer := range sortedCustomers(eStore) {
+			switches += eCustomer.switches
+			if eCustomer.switches > 0 {
+				switchers++
+			}
+			if eCustomer.switches > mostSwitches {
+				mostSwitches = eCustomer.switches
+			}
+		}
+
+		with := collectStatistics(sim, kStore).wait
+		without := collectStatistics(baseline, kStore).wait
+		reduction := 0.0
+		if without.variance > 0 {
+			reduction = 100 * (without.variance - with.variance) / without.variance
+		}
+
+		fmt.Fprintf(out, "===Store: %s jockeying\n", kStore)
+		fmt.Fprintf(out, "Switches: %d by %d customers, at most %d by one customer\n", switches, switchers, mostSwitches)
+		fmt.Fprintf(out, "Wait variance (min^2): %.2f with jockeying, %.2f without, %.1f%% less\n",
+			with.variance/3600, without.variance/3600, reduction)
+		fmt.Fprintf(out, "Wait (min): mean %.1f, p90 %.1f with jockeying, mean %.1f, p90 %.1f without\n",
+			minutes(with.mean), minutes(with.p90), minutes(without.mean), minutes(without.p90))
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestJockeying(t *testing.T) {
+	// Three tills in a busy morning, the middle one an express lane nobody cheats at.
+	busy := map[string]string{
+		"openingHours":                "9-12",
+		"busyRange":                   "B",
+		"numberOfCheckouts":           "3",
+		"maxQueueTime":                "1440-1440",
+		"maxQueueCustomers":           "100000-100000",
+		"[store1][checkout2]maxItems": "10",
+		"expressCheatChance":          "0",
+	}
+
+	tests := []struct {
+		name         string
+		answers      map[string]string
+		wantSwitches bool
+	}{
+		{"no jockeying", map[string]string{"jockeying": "N"}, false},
+		{"nobody feels like it", map[string]string{"jockeying": "Y", "jockeyThreshold": "1", "jockeyPropensity": "0-0"}, false},
+		{"everybody switches", map[string]string{"jockeying": "Y", "jockeyThreshold": "1", "jockeyPropensity": "1-1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answers := map[string]string{}
+			for code, text := range busy {
+				answers[code] = text
+			}
+			for code, text := range tt.answers {
+				answers[code] = text
+			}
+			eStore := runTestDay(t, 4, answers).stores["store1"]
+
+			switches := 0
+			for _, eCustomer := range sortedCustomers(eStore) {
+				switches += eCustomer.switches
+				if eCustomer.outcome == outcomeServed && eCustomer.checkoutId == 2 && eCustomer.items > 10 {
+					t.Errorf("customer %d moved to the express lane with %d items", eCustomer.customerId, eCustomer.items)
+				}
+			}
+			if (switches > 0) != tt.wantSwitches {
+				t.Errorf("%d switches, want switches %v", switches, tt.wantSwitches)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// journeyRecord is one row of the journey export, one per customer.
+type journeyRecord struct {
+	Store          int    `json:"store"`
+	Customer       int    `json:"customer"`
+	ArrivalTime    string `json:"arrival_time"`
+	ArrivalSeconds int64  `json:"arrival_seconds"`
+	ShoppingSecs   int64  `json:"shopping_seconds"`
+	Checkout       int    `json:"checkout"`
+	RoutingPolicy  string `json:"routing_policy"`
+	Decision       string `json:"arrival_decision"`
+	Switches       int    `json:"switches"`
+	WaitSeconds    int64  `json:"wait_seconds"`
+	ServiceSeconds int64  `json:"service_seconds"`
+	Items          int    `json:"items"`
+	PaymentMethod  string `json:"payment_method"`
+	Outcome        string `json:"outcome"`
+}
+
+var journeyHeader = []string{
+	"store", "customer", "arrival_time", "arrival_seconds", "shopping_seconds", "checkout", "routing_policy",
+	"arrival_decision", "switches", "wait_seconds", "service_seconds", "items", "payment_method", "outcome",
+}
+
+// journeyRecords collects every customer of every store, in order of arrival.
+func journeyRecords(stores map[string]*store) []journeyRecord {
+	var records []journeyRecord
+
+	for _, kStore := range sortedStoreKeys(stores) {
+		eStore := stores[kStore]
+		customers := sortedCustomers(eStore)
+		sort.SliceStable(customers, func(i, j int) bool {
+			return customers[i].arrivalTime < customers[j].arrivalTime
+		})
+
+		for _, eCustomer := range customers {
+			outcome := eCustomer.outcome
+			if outcome == "" {
+				// Still in the store when the simulation stopped.
+				outcome = "unfinished"
+			}
+			records = append(records, journeyRecord{
+				Store:          eStore.storeId,
+				Customer:       eCustomer.customerId,
+				ArrivalTime:    formatSimTime(eCustomer.arrivalTime),
+				ArrivalSeconds: eCustomer.arrivalTime,
+				ShoppingSecs:   int64(eCustomer.shoppingSeconds),
+				Checkout:       eCustomer.checkoutId,
+				RoutingPolicy:  eCustomer.routingPolicy,
+				Decision:       eCustomer.arrivalDecision,
+				Switches:       eCustomer.switches,
+				WaitSeconds:    eCustomer.queueTimeSeconds,
+				ServiceSeconds: eCustomer.checkoutTime,
+				Items:          eCustomer.items,
+				PaymentMethod:  eCustomer.paymentMethod,
+				Outcome:        outcome,
+			})
+		}
+	}
+
+	return records
+}
+
+func writeJourneysCSV(w io.Writer, records []journeyRecord) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(journeyHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		err := out.Write([]string{
+			strconv.Itoa(r.Store),
+			strconv.Itoa(r.Customer),
+			r.ArrivalTime,
+			strconv.FormatInt(r.ArrivalSeconds, 10),
+			strconv.FormatInt(r.ShoppingSecs, 10),
+			strconv.Itoa(r.Checkout),
+			r.RoutingPolicy,
+			r.Decision,
+			strconv.Itoa(r.Switches),
+			strconv.FormatInt(r.WaitSeconds, 10),
+			strconv.FormatInt(r.ServiceSeconds, 10),
+			strconv.Itoa(r.Items),
+			r.PaymentMethod,
+			r.Outcome,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeJourneysJSONL(w io.Writer, records []journeyRecord) error {
+	encoder := json.NewEncoder(w)
+	for _, r := range records {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// journeyFormat works out csv or jsonl, from the flag if given or else the file extension.
+func journeyFormat(path string, format string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jsonl", ".ndjson":
+			format = "jsonl"
+		default:
+			format = "csv"
+		}
+	}
+	format = strings.ToLower(format)
+	if format != "csv" && format != "jsonl" {
+		return "", fmt.Errorf("unknown journey format %q, use csv or jsonl", format)
+	}
+	return format, nil
+}
+
+// journeyExport is opened once the day is set up and before it runs, so a bad path is a
+// configuration error and not something we find out after simulating the whole day, and a
+// bad configuration does not leave an empty file behind.
+type journeyExport struct {
+	file   *os.File
+	format string
+}
+
+func openJourneyExport(path string, format string) (*journeyExport, error) {
+	format, err := journeyFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &journeyExport{file: file, format: format}, nil
+}
+
+func (je *journeyExport) write(stores map[string]*store) error {
+	records := journeyRecords(stores)
+
+	var err error
+	if je.format == "jsonl" {
+		err = writeJourneysJSONL(je.file, records)
+	} else {
+		err = writeJourneysCSV(je.file, records)
+	}
+	if closeErr := je.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// close closes the file once, it is safe to defer next to write.
+func (je *journeyExport) close() error {
+	if je.file == nil {
+		return nil
+	}
+	err := je.file.Close()
+	je.file = nil
+	return err
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Which payments a lane takes.
+const (
+	lanePaymentAny  = "ANY"
+	lanePaymentCash = "CASH-ONLY"
+	lanePaymentCard = "CARD-ONLY"
+)
+
+// laneExpressNever is the expressHours answer for a lane that keeps its own item limit all day.
+const laneExpressNever = "NEVER"
+
+// cheatTolerance is how far over an item limit a customer that cheats will go, 1.5 is
+// half as many items again.
+const cheatTolerance = 1.5
+
+// expressPeriod turns a lane into an express lane for maxItems between from and to (hours).
+type expressPeriod struct {
+	from     int
+	to       int
+	maxItems int
+}
+
+// lanePolicy is who a checkout takes on top of its maxItems, see canUseCheckout.
+type lanePolicy struct {
+	payment string
+	express []expressPeriod
+	// accessible lanes are wider, customers that need them go there first and are served
+	// before the others in its line.
+	accessible bool
+}
+
+// laneSettings are the store-wide chances that go with the lane policies.
+type laneSettings struct {
+	// cheatChance is how likely a customer ignores an item limit, up to cheatTolerance.
+	cheatChance float64
+	// accessibilityNeed is how likely a customer needs an accessible lane.
+	accessibilityNeed float64
+}
+
+// parseExpressHours reads "12-14:10,17-19:10", express for 10 items or fewer from 12 to 14
+// and from 17 to 19.
+func parseExpressHours(text string) ([]expressPeriod, error) {
+	if strings.TrimSpace(text) == laneExpressNever {
+		return nil, nil
+	}
+	var periods []expressPeriod
+	for _, part := range strings.Split(text, ",") {
+		hours, limitText, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found {
+			return nil, fmt.Errorf("%q is not from-to:items, for example 12-14:10", part)
+		}
+		period, err := parseIntRange(hours, 0, 24)
+		if err != nil {
+			return nil, err
+		}
+		if period.from == period.to {
+			return nil, fmt.Errorf("%q: the express hours have to end after they start", part)
+		}
+		maxItems, err := parseInt(limitText, 1, 10000)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", part, err)
+		}
+		periods = append(periods, expressPeriod{from: period.from, to: period.to, maxItems: maxItems})
+	}
+	return periods, nil
+}
+
+func readLanePolicy(iStore int, iCheckout int, defaultSettingsCode string) (lanePolicy, error) {
+	var policy lanePolicy
+	prefix := "[Store " + strconv.Itoa(iStore) + "][Checkout " + strconv.Itoa(iCheckout) + "] "
+	code := "[store" + strconv.Itoa(iStore) + "][checkout" + strconv.Itoa(iCheckout) + "]"
+
+	var err error
+	policy.payment, err = readChoice(
+		prefix+"Which payments does this checkout take? [ANY/cash-only/card-only]:",
+		lanePaymentAny,
+		defaultSettingsCode,
+		code+"lanePayment",
+		lanePaymentAny, lanePaymentCash, lanePaymentCard)
+	if err != nil {
+		return policy, err
+	}
+	err = readValidFromConsole(
+		prefix+"Is this checkout an express lane at busy times? For example 12-14:10,17-19:10 is 10 items or "+
+			"fewer from 12 to 14 and 17 to 19 [NEVER] ",
+		laneExpressNever,
+		defaultSettingsCode,
+		code+"expressHours",
+		func(text string) (err error) {
+			policy.express, err = parseExpressHours(text)
+			return err
+		})
+	if err != nil {
+		return policy, err
+	}
+	accessible, err := readChoice(
+		prefix+"Is this checkout an accessible lane, serving customers that need it first? [y/N]:",
+		"N",
+		defaultSettingsCode,
+		code+"accessible",
+		"Y", "N")
+	policy.accessible = accessible == "Y"
+	return policy, err
+}
+
+func readLaneSettings(iStore int, defaultSettingsCode string) (laneSettings, error) {
+	var settings laneSettings
+	prefix := "[Store " + strconv.Itoa(iStore) + "] "
+	code := "[store" + strconv.Itoa(iStore) + "]"
+
+	var err error
+	settings.cheatChance, err = readFloat(
+		prefix+"How likely is a customer to take too many items to an express lane? From 0 to 1 [0] ",
+		"0",
+		defaultSettingsCode,
+		code+"expressCheatChance",
+		0, 1)
+	if err != nil {
+		return settings, err
+	}
+	settings.accessibilityNeed, err = readFloat(
+		prefix+"How likely does a customer need an accessible lane? From 0 to 1 [0] ",
+		"0",
+		defaultSettingsCode,
+		code+"accessibilityNeed",
+		0, 1)
+	return settings, err
+}
+
+// itemLimit is the most items the checkout takes at the time given, 0 means no limit. An
+// express period can only lower the checkout's own maxItems.
+func (c *checkout) itemLimit(at float64) int {
+	limit := c.maxItems
+	hour := int(at / 3600)
+	for _, period := range c.lane.express {
+		if hour >= period.from && hour < period.to && (limit == 0 || period.maxItems < limit) {
+			limit = period.maxItems
+		}
+	}
+	return limit
+}
+
+// takesPayment tells if the lane takes the customer's way of paying. Split tender ends
+// on a card, a voucher is neither.
+func (p lanePolicy) takesPayment(method string) bool {
+	switch p.payment {
+	case lanePaymentCash:
+		return method == paymentCash
+	case lanePaymentCard:
+		return method == paymentContactless || method == paymentChipAndPin ||
+			method == paymentMobileWallet || method == paymentSplit
+	}
+	return true
+}
+
+// withinItemLimit is the basket against the limit, with the customers that cheat going up
+// to cheatTolerance times the limit.
+func withinItemLimit(limit int, customer *customer) bool {
+	if limit == 0 || customer.items <= limit {
+		return true
+	}
+	return customer.cheatsItemLimit && float64(customer.items) <= cheatTolerance*float64(limit)
+}
+
+// joinLine puts the customer at the back of the checkout's line, or in an accessible lane
+// behind the others that need it if they do. A customer over the item limit is noted down
+// as cheating.
+func joinLine(store *store, checkout *checkout, customer *customer) {
+	queue := store.sim.queues[getQueueIndex(store, checkout)]
+	if limit := checkout.itemLimit(store.sim.engine.now); limit > 0 && customer.items > limit {
+		customer.cheated = true
+	}
+	if !checkout.lane.accessible || !customer.needsAccessibility {
+		queue.put(customer)
+		return
+	}
+	at := 0
+	for at < queue.len() && queue.items[at].needsAccessibility {
+		at++
+	}
+	queue.putAt(at, customer)
+}
+
+// canRouteTo is canUseCheckout plus the priority of the accessible lanes: a customer
+// that needs one is only sent elsewhere when no accessible lane takes them.
+func canRouteTo(store *store, checkout *checkout, customer *customer) bool {
+	if !canUseCheckout(store, checkout, customer) {
+		return false
+	}
+	if !customer.needsAccessibility || checkout.lane.accessible {
+		return true
+	}
+	for _, eCheckout := range store.checkouts {
+		if eCheckout.lane.accessible && canUseCheckout(store, eCheckout, customer) {
+			return false
+		}
+	}
+	return true
+}
+
+// restricted tells if the lane is choosier than its item limit: it takes only some
+// payments, turns express at times or puts some customers first. Such a lane keeps its own
+// line in every queue topology.
+func (p lanePolicy) restricted() bool {
+	return len(p.express) > 0 || p.accessible || (p.payment != lanePaymentAny && p.payment != "")
+}
+
+// describe is the lane's rules in a few words for the reports.
+func (c *checkout) describe() string {
+	var rules []string
+	if c.maxItems > 0 {
+		rules = append(rules, "max "+strconv.Itoa(c.maxItems))
+	}
+	for _, period := range c.lane.express {
+		rules = append(rules, fmt.Sprintf("max %d %d-%d", period.maxItems, period.from, period.to))
+	}
+	if c.lane.payment != lanePaymentAny && c.lane.payment != "" {
+		rules = append(rules, strings.ToLower(c.lane.payment))
+	}
+	if c.lane.accessible {
+		rules = append(rules, "accessible")
+	}
+	return strings.Join(rules, ", ")
+}
+
+// hasLaneRules tells if the store has lanes with more rules than an item limit, or
+// customers that cheat or need an accessible lane.
+func hasLaneRules(store *store) bool {
+	if store.lanes.cheatChance > 0 || store.lanes.accessibilityNeed > 0 {
+		return true
+	}
+	for _, eCheckout := range store.checkouts {
+		if eCheckout.lane.restricted() {
+			return true
+		}
+	}
+	return false
+}
+
+// printLaneReport shows who every lane with rules served, and who cheated.
+func printLaneReport(out io.Writer, eStore *store) {
+	if !hasLaneRules(eStore) {
+		return
+	}
+	served := map[int]int{}
+	cheaters := map[int]int{}
+	priority := map[int]int{}
+	for _, eCustomer := range sortedCustomers(eStore) {
+		if eCustomer.outcome != outcomeServed {
+			continue
+		}
+		served[eCustomer.checkoutId]++
+		if eCustomer.cheated {
+			cheaters[eCustomer.checkoutId]++
+		}
+		if eCustomer.needsAccessibility {
+			priority[eCustomer.checkoutId]++
+		}
+	}
+
+	fmt.Fprintf(out, "%-12s %-32s %6s %9s %13s\n", "Lane", "Rules", "Served", "Over limit", "Accessibility")
+	for _, kCheckout := range sortedCheckoutKeys(eStore) {
+		eCheckout := eStore.checkouts[kCheckout]
+		rules := eCheckout.describe()
+		if rules == "" {
+			continue
+		}
+		fmt.Fprintf(out, "%-12s %-32s %6d %9d %13d\n", eCheckout.label(), rules,
+			served[eCheckout.checkoutId], cheaters[eCheckout.checkoutId], priority[eCheckout.checkoutId])
+	}
+}
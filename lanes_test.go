@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestLanePolicies(t *testing.T) {
+	// checkout1 takes cards only, checkout2 is an express lane from 10 to 11 and checkout3
+	// takes everybody.
+	eStore := runTestDay(t, 6, map[string]string{
+		"openingHours":                    "9-12",
+		"numberOfCheckouts":               "3",
+		"maxQueueTime":                    "1440-1440",
+		"maxQueueCustomers":               "100000-100000",
+		"paymentMix":                      "cash=1,contactless=1",
+		"declineChance":                   "0",
+		"expressCheatChance":              "0",
+		"[store1][checkout1]lanePayment":  lanePaymentCard,
+		"[store1][checkout2]expressHours": "10-11:5",
+	}).stores["store1"]
+
+	cardOnly, cash, expressOffHours := 0, 0, 0
+	for _, eCustomer := range sortedCustomers(eStore) {
+		if eCustomer.outcome != outcomeServed {
+			continue
+		}
+		switch eCustomer.checkoutId {
+		case 1:
+			cardOnly++
+			if eCustomer.paymentMethod == paymentCash {
+				t.Errorf("customer %d paid cash at the card-only lane", eCustomer.customerId)
+			}
+		case 2:
+			if eCustomer.queueTimeStart >= 10*3600 && eCustomer.queueTimeStart < 11*3600 {
+				if eCustomer.items > 5 {
+					t.Errorf("customer %d joined the express lane at %s with %d items", eCustomer.customerId,
+						formatSimTime(eCustomer.queueTimeStart), eCustomer.items)
+				}
+			} else if eCustomer.items > 5 {
+				expressOffHours++
+			}
+		}
+		if eCustomer.paymentMethod == paymentCash {
+			cash++
+		}
+	}
+	if cardOnly == 0 || cash == 0 || expressOffHours == 0 {
+		t.Errorf("%d served at the card-only lane, %d paid cash and %d big baskets went through checkout2 "+
+			"outside its express hours, want all of them", cardOnly, cash, expressOffHours)
+	}
+}
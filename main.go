@@ -9,6 +9,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"strconv"
@@ -22,15 +23,45 @@ type store struct {
 	checkouts                        map[string]*checkout
 	busyRanges                       map[string]busyRange
 	weather                          optionFactor
-	openingHours                     string
+	openingHours                     intRange
 	openingHoursFrom                 int
 	openingHoursTo                   int
 	totalCustomers                   int
+	expectedArrivals                 map[int]float64
 	customers                        map[string]*customer
 	processedCustomers               SafeCounter
 	notProcessedCustomersQueuingTime SafeCounter
 	notProcessedCustomersQueuingDeep SafeCounter
+	notProcessedCustomersClosing     SafeCounter
+	arrivalsJoined                   SafeCounter
+	arrivalsOtherLane                SafeCounter
 	hasFloorManager                  bool
+	floorManager                     floorManagerSettings
+	decisions                        []managerDecision
+	closingPolicy                    string
+	queueTopology                    string
+	customersShopping                int
+	doorsClosed                      bool
+	linesClosed                      bool
+	jockeying                        bool
+	jockeyThreshold                  int
+	// shorterLines are the lines lineGotShorter still has to show the neighbours of,
+	// lookingAround is set while it does.
+	shorterLines       []*checkout
+	lookingAround      bool
+	handoverSeconds    float64
+	checkoutSelector   CheckoutSelector
+	productProcessTime floatRange
+	selfCheckout       selfCheckoutSettings
+	payments           paymentSettings
+	lanes              laneSettings
+	catalogue          *catalogue
+	exceptions         map[string]exceptionRule
+	economics          economicsSettings
+	supervisor         *helper
+	attendant          *helper
+	rng                *randomStreams
+	sim                *simulation
 }
 
 type busyRange struct {
@@ -43,24 +74,42 @@ type checkout struct {
 	checkoutId           int
 	cashierEfficiency    float64
 	maxItems             int
-	paymentTime          int
 	checkoutDesirability int
 	currentDeep          SafeCounter
 	status               string
 	totalCustomersServed SafeCounter
 	totalItemsCheckedOut SafeCounter
+	busySeconds          float64
+	serving              *customer
+	selfService          bool
+	kioskId              int
+	// roster is who works the till and when, see roster.go. accepting is false while
+	// the till is closed, offDutyAt is when the cashier on the till is next due off.
+	roster     []shift
+	cashier    string
+	accepting  bool
+	offDutyAt  float64
+	handovers  int
+	reassigned int
+	// standby tills are opened and closed by the floor manager, see floormanager.go.
+	// running is true while the till has a process.
+	standby bool
+	running bool
+	lane    lanePolicy
+	// exceptions is the time lost to each kind of exception, see exceptions.go.
+	exceptions map[string]*exceptionTally
+	// hourlyWage is what the cashiers of the till are paid, see readCheckoutWages.
+	hourlyWage float64
 }
 
-func (c *checkout) scanProduct(customer *customer, product *product) {
+func (c *checkout) scanProduct(sim *simulation, customer *customer, product *product) {
 	// Scan according to product scan time and cashier efficiency
-	_, simWorldCurrentTimeString := dualClock.getSimWorldCurrentTime()
-
 	// Some output in console to see the progress of the simulation.
-	fmt.Printf("%s:Checkout%2d: SCANNING -> Customer: %3d, Product: %4d | SimScanTime;%5.2f;\n",
-		simWorldCurrentTimeString, c.checkoutId, customer.customerId, product.productId, product.processTimeSecond*c.cashierEfficiency)
+	sim.logf("Checkout%2d: SCANNING -> Customer: %3d, Product: %4d | SimScanTime;%5.2f;\n",
+		c.checkoutId, customer.customerId, product.productId, product.processTimeSecond*c.cashierEfficiency)
 
 	// Simulate scanning product
-	dualClock.scaleSleepTimeForSimulation(product.processTimeSecond * c.cashierEfficiency)
+	sim.sleep(product.processTimeSecond * c.cashierEfficiency)
 
 	// Mark this item as scanned.
 	c.totalItemsCheckedOut.Inc()
@@ -75,14 +124,63 @@ type customer struct {
 	queueTimeSeconds    int64
 	maxQueueTimeSeconds int64
 	maxQueueCustomers   int
+	maxQueueItems       int
 	purchaseComplete    bool
 	leftQueue           bool
 	checkoutTime        int64
 	checkoutTimeStart   int64
 	checkoutTimeEnd     int64
 	products            map[string]product
+	arrivalTime         int64
+	scheduledArrival    float64
+	renegeEvent         *simEvent
+	routingPolicy       string
+	arrivalDecision     string
+	jockeyPropensity    float64
+	switches            int
+	shoppingSeconds     float64
+	prefersSelfCheckout bool
+	paymentMethod       string
+	paymentSeconds      float64
+	paymentDeclines     int
+	// cheatsItemLimit customers take up to cheatTolerance times the item limit to an express
+	// lane, cheated is set when they did. needsAccessibility customers go to the accessible lanes first.
+	cheatsItemLimit    bool
+	cheated            bool
+	needsAccessibility bool
+	// basketPrice is what the shopping costs, see economicsSettings.
+	basketPrice float64
+	// rng are the customer's own random streams for the draws made at the checkout.
+	rng              *customerStreams
+	reachedCheckouts int64
+	departureTime    int64
+	outcome          string
 }
 
+// How a customer's visit ended, written to the journey export.
+const (
+	outcomeServed  = "served"
+	outcomeReneged = "reneged"
+	outcomeBalked  = "balked"
+	// Still queuing at closing time in a store that turns them away.
+	outcomeTurnedAway = "turned-away"
+	// Every card was declined at a lane that takes no cash.
+	outcomePaymentFailed = "payment-failed"
+)
+
+// What a customer does on seeing the line at the checkout they were sent to.
+const (
+	arrivalJoined    = "joined"
+	arrivalOtherLane = "other-lane"
+	arrivalLeft      = "left"
+)
+
+// What happens at closing time to customers that are still queuing.
+const (
+	closingPolicyServe    = "SERVE"
+	closingPolicyTurnAway = "TURN-AWAY"
+)
+
 type dualTimeClock struct {
 	secondsAreOneHour    int
 	realWorldStartTime   int64
@@ -99,28 +197,38 @@ func (dtc *dualTimeClock) initSimWorldDayClock(storeOpenTimeHoursInt int) {
 	dtc.simWorldStartTime = 0 // it's groundhog day!
 	openingTimeInSeconds := int64(60 * 60 * storeOpenTimeHoursInt)
 	dtc.simWorldStartTime = openingTimeInSeconds // it's opening time!
+	dtc.simWorldCurrentTime = openingTimeInSeconds
 }
 func (dtc *dualTimeClock) getRealWorldCurrentTime() int64 {
 	dtc.realWorldCurrentTime = time.Now().UnixNano()
 	return dtc.realWorldCurrentTime
 }
 func (dtc *dualTimeClock) getSimWorldCurrentTime() (int64, string) {
-	// So we know that secondsAreOneHour seconds in the real world == 3600 seconds in sim world
-	// Let's assume the minimum value for secondsAreOneHour == 1
-	// secondsAreOneHour milliseconds in the real world == 3.6 seconds in sim world
-	// secondsAreOneHour microseconds in the real world == 0.0036 seconds in sim world
-	// Surely ^that's^ adequate resolution?
-	// secondsAreOneHour nanoseconds in the real world == 0.0000036 seconds in sim world
-	// 1 microsecond in the real world = (0.0036/secondsAreOneHour) seconds in sim world
-	var secondsSinceOpening float64
-	var secondsSinceStoreEpoch int64
-	realWorldMicroSecondsElapsed := (time.Now().UnixNano() - dtc.realWorldStartTime) / 1000
-	scalingRealTimeToSimTime := 0.0036 / float64(dtc.secondsAreOneHour)
-	secondsSinceOpening = float64(realWorldMicroSecondsElapsed) * scalingRealTimeToSimTime
-	secondsSinceStoreEpoch = dtc.simWorldStartTime + int64(secondsSinceOpening)
-	unixTime := time.Unix(secondsSinceStoreEpoch, 0)
-	humanReadableTimeString := fmt.Sprintf("%02d:%02d:%02d", unixTime.Hour(), unixTime.Minute(), unixTime.Second())
-	return secondsSinceStoreEpoch, humanReadableTimeString
+	// The sim world time is no longer worked out from the real world clock, the event
+	// engine moves simWorldCurrentTime forward as it fires events. In real time mode the
+	// engine sleeps in between (see sleepUntilSimTime) so both clocks still agree,
+	// in discrete-event mode it just jumps to the next event.
+	return dtc.simWorldCurrentTime, formatSimTime(dtc.simWorldCurrentTime)
+}
+
+// formatSimTime turns seconds since midnight into HH:MM:SS.
+// We do not go through time.Unix as that would apply the local time zone.
+func formatSimTime(secondsSinceMidnight int64) string {
+	return fmt.Sprintf("%02d:%02d:%02d",
+		secondsSinceMidnight/3600, (secondsSinceMidnight%3600)/60, secondsSinceMidnight%60)
+}
+
+// sleepUntilSimTime is used by the engine in real time mode. It works out where the
+// sim world clock would be going by the real world clock and sleeps the difference,
+// so the small delays after each event do not add up over the day.
+func (dtc *dualTimeClock) sleepUntilSimTime(simSeconds float64) {
+	// secondsAreOneHour REAL WORLD seconds == 3600 Simulated seconds
+	realWorldSecondsElapsed := float64(dtc.getRealWorldCurrentTime()-dtc.realWorldStartTime) / float64(time.Second)
+	simWorldSecondsElapsed := realWorldSecondsElapsed * 3600 / float64(dtc.secondsAreOneHour)
+	remaining := simSeconds - (float64(dtc.simWorldStartTime) + simWorldSecondsElapsed)
+	if remaining > 0 {
+		dtc.scaleSleepTimeForSimulation(remaining)
+	}
 }
 
 func (dtc *dualTimeClock) diffInSeconds(start int64, end int64) int64 {
@@ -130,7 +238,7 @@ func (dtc *dualTimeClock) diffInSeconds(start int64, end int64) int64 {
 
 func (dtc dualTimeClock) scaleSleepTimeForSimulation(seconds float64) {
 	// Work in seconds usually for easier human understanding,
-	// then call this function for any sleep times in the simulated world
+	// the engine calls this function (through sleepUntilSimTime) when running in real time mode
 	// secondsAreOneHour REAL WORLD seconds == 60 * 60 == 3600 Simulated seconds
 	// so a sleep for 9 seconds in the simulation corresponds to
 	// 9 * secondsAreOneHour/3600 in the Real world.
@@ -168,6 +276,8 @@ var busyRangeOptions = map[string]optionFactor{
 type product struct {
 	productId         int
 	processTimeSecond float64
+	// item is what the product is when the store has a catalogue, see catalogue.go.
+	item *catalogueItem
 }
 
 // SafeCounter is safe to use concurrently.
@@ -200,135 +310,173 @@ func (c *SafeCounter) Value() int {
 	return c.v
 }
 
+// console is where the prompts and the answers go, report sends them to io.Discard.
+var console io.Writer = os.Stdout
+
+// interactive is false when running from a script, prompts then take their default value.
+var interactive = true
+
+// stdin is shared by every prompt, a new reader per prompt would lose piped input.
+var stdin = bufio.NewReader(os.Stdin)
+
 func readFromConsole(label string, convertToUpper bool, defaultValue string, defaultSettingsCode string, code string) string {
 
-	fmt.Print(label + "\n")
+	fmt.Fprint(console, label+"\n")
 
-	if defaultSettingsCode == "Y" && code != "defaultSettingsCode" {
-		// We use default setting value when it is not defined in the defaultScenarios.
-		fmt.Print(defaultValue + "\n")
-		return defaultValue
-	} else if defaultScenarios[defaultSettingsCode+"_"+code] != "" {
-		// We use default settings from scenario whenever it exists.
-		fmt.Print(defaultScenarios[defaultSettingsCode+"_"+code] + "\n")
-		return defaultScenarios[defaultSettingsCode+"_"+code]
-	} else if defaultSettingsCode != "N" && code != "defaultSettingsCode" && defaultScenarios[defaultSettingsCode+"_"+code] == "" {
-		// When it is not defined in the scenario, but you have selected Y to default settings it will use this default value
-		fmt.Print(defaultValue + "\n")
-		return defaultValue
-	}
+	text, found := lookupSetting(defaultValue, defaultSettingsCode, code)
+	if !found {
+		// Otherwise, it will ask the Store Manager input.
+		if interactive {
+			text, _ = stdin.ReadString('\n')
+			text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		}
 
-	// Otherwise, it will ask the Store Manager input.
-	reader := bufio.NewReader(os.Stdin)
-	text, _ := reader.ReadString('\n')
-	text = strings.TrimSuffix(text, "\n")
+		// If the manager did not provide an input we use the default value, useful if you want to modify some values only
+		if text == "" {
+			text = defaultValue
+		}
+	}
 
-	// We convert to Upper case for consistency dealing with strings
+	// We convert to Upper case for consistency dealing with strings,
+	// scenario files are written by hand so they get the same treatment as typed input.
 	if convertToUpper {
 		text = strings.ToUpper(text)
 	}
 
-	// If the manager did not provide an input we use the default value, useful if you want to modify some values only
-	if text == "" {
-		text = defaultValue
-	}
-
-	fmt.Print(text + "\n")
+	fmt.Fprint(console, text+"\n")
+	answersGiven[code] = text
 	return text
 }
 
-func generateRandomNumber(min int, max int) int {
-	return rand.Intn(max-min+1) + min
+// lookupSetting finds the answer to a prompt without asking for it, from the command line,
+// the scenario or the defaults. found is false when the Store Manager has to be asked.
+func lookupSetting(defaultValue string, defaultSettingsCode string, code string) (text string, found bool) {
+
+	if text, ok := promptOverride(code); ok {
+		// A value given on the command line wins over scenarios and defaults.
+		return text, true
+	}
+
+	if defaultSettingsCode == "Y" && code != "defaultSettingsCode" {
+		// We use default setting value when it is not defined in the defaultScenarios.
+		return defaultValue, true
+	} else if defaultScenarios[defaultSettingsCode+"_"+code] != "" {
+		// We use default settings from scenario whenever it exists.
+		return defaultScenarios[defaultSettingsCode+"_"+code], true
+	} else if defaultSettingsCode != "N" && code != "defaultSettingsCode" && fileScenarios[defaultSettingsCode] == "" &&
+		defaultScenarios[defaultSettingsCode+"_"+code] == "" {
+		// When it is not defined in the scenario, but you have selected Y to default settings it will use this default value.
+		// Scenarios loaded from a file fall through to the prompt instead.
+		return defaultValue, true
+	}
+
+	return "", false
 }
 
-func getBusyFactor(store *store) float64 {
-	_, currentTime := dualClock.getSimWorldCurrentTime()
-	//Example: 08 becomes 8
-	hour, _ := strconv.Atoi(currentTime[0:2])
+// isAskedInConsole tells if the answer to code would be typed by the Store Manager.
+func isAskedInConsole(defaultSettingsCode string, code string) bool {
+	_, found := lookupSetting("", defaultSettingsCode, code)
+	return !found
+}
 
-	return float64(store.busyRanges["busyRange_"+strconv.Itoa(hour)].busyOptionFactor.factor)
+func generateRandomNumber(stream *rand.Rand, min int, max int) int {
+	return stream.Intn(max-min+1) + min
 }
 
 func openCheckout(store *store, checkoutName string, checkout *checkout) {
 
-	fmt.Println("Opening: " + checkoutName)
+	sim := store.sim
+	fmt.Fprintln(sim.out, "Opening: "+checkoutName)
+	current, _ := checkout.onDuty(sim.engine.now)
+	checkout.accepting = current != nil
+	scheduleClosings(store, checkout)
 
 	for {
 		//Time between one payment and next person
-		dualClock.scaleSleepTimeForSimulation(float64(30))
-		queueIndex := getQueueIndex(store, checkout)
-		customer := <-queues[queueIndex]
-		customer.queueTimeEnd, _ = dualClock.getSimWorldCurrentTime()
-
-		if customer.queueTimeStart != customer.queueTimeEnd {
-			customer.queueTimeSeconds = dualClock.diffInSeconds(customer.queueTimeStart, customer.queueTimeEnd)
+		sim.sleep(checkoutChangeoverSeconds)
+		customer := nextCustomer(store, checkout)
+		if customer == nil {
+			// The store is closed and nobody is left in the line, or the last shift is over.
+			sim.logf("Closing: %s\n", checkoutName)
+			checkout.running = false
+			sim.checkoutShut()
+			return
 		}
-
-		// Customer leaving because waiting longer than what he wants to wait.
-		if customer.queueTimeSeconds > customer.maxQueueTimeSeconds {
-			customer.leftQueue = true
-			store.notProcessedCustomersQueuingTime.Inc()
-			ch <- 1
-			continue
+		// Made it to the front in time, see scheduleRenege.
+		sim.engine.cancel(customer.renegeEvent)
+		if customer.checkoutId != checkout.checkoutId {
+			// A shared line, the customer was counted at the checkout they were sent to.
+			sim.logf("Next till please: Customer %4d to Checkout %2d\n", customer.customerId, checkout.checkoutId)
+			store.checkouts["checkout"+strconv.Itoa(customer.checkoutId)].currentDeep.Dec()
+			checkout.currentDeep.Inc()
 		}
+		customer.queueTimeEnd, _ = sim.clock.getSimWorldCurrentTime()
 
-		// Customer leaving because queue was deeper than what he wants to wait.
-		if checkout.currentDeep.Value() > customer.maxQueueCustomers {
-			customer.leftQueue = true
-			store.notProcessedCustomersQueuingDeep.Inc()
-			ch <- 1
-			continue
+		if customer.queueTimeStart != customer.queueTimeEnd {
+			customer.queueTimeSeconds = sim.clock.diffInSeconds(customer.queueTimeStart, customer.queueTimeEnd)
 		}
 
 		customer.checkoutId = checkout.checkoutId
-		customer.checkoutTimeStart, _ = dualClock.getSimWorldCurrentTime()
+		customer.checkoutTimeStart, _ = sim.clock.getSimWorldCurrentTime()
+		busySince := sim.engine.now
 		checkout.status = "BUSY"
-		_, simWorldCurrentTimeString := dualClock.getSimWorldCurrentTime()
-		fmt.Printf("%s:Customer %4d arrived at Checkout %2d with %3d items\n",
-			simWorldCurrentTimeString, customer.customerId, checkout.checkoutId, customer.items)
-		for _, eProduct := range customer.products {
-			checkout.scanProduct(customer, &eProduct)
-		}
-		_, simWorldCurrentTimeString = dualClock.getSimWorldCurrentTime()
-		fmt.Printf("%s:Customer %4d is paying at Checkout %2d...\n",
-			simWorldCurrentTimeString, customer.customerId, checkout.checkoutId)
-		dualClock.scaleSleepTimeForSimulation(float64(checkout.paymentTime))
-		_, simWorldCurrentTimeString = dualClock.getSimWorldCurrentTime()
-		fmt.Printf("%s:Customer %4d is finished at Checkout %2d.\n",
-			simWorldCurrentTimeString, customer.customerId, checkout.checkoutId)
-
-		customer.purchaseComplete = true
+		checkout.serving = customer
+		sim.logf("Customer %4d arrived at Checkout %2d with %3d items\n",
+			customer.customerId, checkout.checkoutId, customer.items)
+		if checkout.selfService {
+			selfScan(store, checkout, customer)
+		} else {
+			checkId(store, checkout, customer)
+			for _, eProduct := range sortedProducts(customer) {
+				checkout.scanProduct(sim, customer, &eProduct)
+				scanExceptions(store, checkout, customer)
+			}
+		}
+		if pay(store, checkout, customer) {
+			sim.logf("Customer %4d is finished at Checkout %2d.\n",
+				customer.customerId, checkout.checkoutId)
+			customer.purchaseComplete = true
+			customer.outcome = outcomeServed
+			checkout.totalCustomersServed.Inc()
+			store.processedCustomers.Inc()
+		} else {
+			customer.outcome = outcomePaymentFailed
+		}
+
+		customer.departureTime, _ = sim.clock.getSimWorldCurrentTime()
 		checkout.status = "IDLE"
-		checkout.totalCustomersServed.Inc()
+		checkout.serving = nil
+		checkout.busySeconds += sim.engine.now - busySince
 		checkout.currentDeep.Dec()
-		store.processedCustomers.Inc()
-		customer.checkoutTimeEnd, _ = dualClock.getSimWorldCurrentTime()
-		customer.checkoutTime = dualClock.diffInSeconds(customer.checkoutTimeStart, customer.checkoutTimeEnd)
-		c.Inc()
-		ch <- 1
+		customer.checkoutTimeEnd, _ = sim.clock.getSimWorldCurrentTime()
+		customer.checkoutTime = sim.clock.diffInSeconds(customer.checkoutTimeStart, customer.checkoutTimeEnd)
+		lineGotShorter(store, checkout)
 	}
 
 }
 
-var c = SafeCounter{v: 0}
-var queues = make(map[string]chan *customer)
-var dualClock dualTimeClock
+// checkoutChangeoverSeconds is the time between one payment and the next person.
+const checkoutChangeoverSeconds = 30
 
-func getCheckoutWithShorterQueue(store *store, nextCustomerNumberOfProducts int) *checkout {
+func getCheckoutWithShorterQueue(store *store, nextCustomer *customer) *checkout {
 
 	lowestDeep := -1
 	var selectedCheckout string
 
-	for kCheckout := range store.checkouts {
+	for _, kCheckout := range sortedCheckoutKeys(store) {
 		// We use array key to avoid copying the counters to a new variable
 		tmpCheckout := store.checkouts[kCheckout]
+		if tmpCheckout.selfService {
+			// Kiosks are chosen by the customer, see chooseSelfCheckout.
+			continue
+		}
 
-		if lowestDeep < 0 && (nextCustomerNumberOfProducts <= tmpCheckout.maxItems || tmpCheckout.maxItems == 0) {
+		if lowestDeep < 0 && canRouteTo(store, tmpCheckout, nextCustomer) {
 			lowestDeep = tmpCheckout.currentDeep.Value()
 			selectedCheckout = kCheckout
 		}
 
-		if tmpCheckout.currentDeep.Value() < lowestDeep && (nextCustomerNumberOfProducts <= tmpCheckout.maxItems || tmpCheckout.maxItems == 0) {
+		if tmpCheckout.currentDeep.Value() < lowestDeep && canRouteTo(store, tmpCheckout, nextCustomer) {
 			lowestDeep = tmpCheckout.currentDeep.Value()
 			selectedCheckout = kCheckout
 		}
@@ -338,68 +486,207 @@ func getCheckoutWithShorterQueue(store *store, nextCustomerNumberOfProducts int)
 	return store.checkouts[selectedCheckout]
 }
 
-func getCheckoutRandomly(store *store, nextCustomerNumberOfProducts int) *checkout {
+func getCheckoutRandomly(store *store, nextCustomer *customer) *checkout {
 
 	tmpCheckouts := make(map[int]string)
 
 	i := 0
 
-	for kCheckout := range store.checkouts {
+	for _, kCheckout := range sortedCheckoutKeys(store) {
 		// We use array key to avoid copying the counters to a new variable
 		tmpCheckout := store.checkouts[kCheckout]
+		if tmpCheckout.selfService {
+			// Kiosks are chosen by the customer, see chooseSelfCheckout.
+			continue
+		}
 
-		if nextCustomerNumberOfProducts <= tmpCheckout.maxItems || tmpCheckout.maxItems == 0 {
+		if canRouteTo(store, tmpCheckout, nextCustomer) {
 			tmpCheckouts[i] = kCheckout
+			i++
 		}
 	}
 
 	rangeEnds := len(tmpCheckouts)
+	if rangeEnds == 0 {
+		// Every till the customer may use is closed, see roster.go.
+		return nil
+	}
 
-	return store.checkouts[tmpCheckouts[generateRandomNumber(1, rangeEnds)]]
+	return store.checkouts[tmpCheckouts[generateRandomNumber(store.rng.routing, 0, rangeEnds-1)]]
 }
 
 func customerSpawning(eStore *store) {
 
+	sim := eStore.sim
 	i := 0
-	for kCustomer := range eStore.customers {
-		//People will arrive every 2 minutes normally.
-		invertedFactor := getBusyFactor(eStore) - 2
-		if invertedFactor < 0 {
-			invertedFactor = 1
+	for _, eCustomer := range sortedCustomers(eStore) {
+		if eCustomer.scheduledArrival >= float64(eStore.openingHoursTo*3600) {
+			// The doors are closed.
+			break
 		}
+		// The arrival times were drawn up front, see arrivals.go.
+		sim.sleep(eCustomer.scheduledArrival - sim.engine.now)
+		eCustomer.arrivalTime, _ = sim.clock.getSimWorldCurrentTime()
 
-		dualClock.scaleSleepTimeForSimulation(120 * invertedFactor)
-		nextCustomerNumberOfProducts := len(eStore.customers[kCustomer].products)
+		// First the aisles, then the checkouts, see shopping.go.
+		eStore.customersShopping++
+		sim.engine.schedule(eCustomer.shoppingSeconds, func() { reachCheckouts(eStore, eCustomer) })
+		i++
+	}
+
+}
 
-		var checkout *checkout
+// reachCheckouts is the customer arriving at the checkouts with a full basket.
+func reachCheckouts(eStore *store, eCustomer *customer) {
+	sim := eStore.sim
+	eStore.customersShopping--
+	defer closeLinesAfterLastShopper(eStore)
+	eCustomer.reachedCheckouts, _ = sim.clock.getSimWorldCurrentTime()
+
+	if eStore.doorsClosed && eStore.closingPolicy == closingPolicyTurnAway {
+		sim.logf("Customer %4d is turned away, the store has closed\n", eCustomer.customerId)
+		eCustomer.leftQueue = true
+		eCustomer.outcome = outcomeTurnedAway
+		eCustomer.departureTime = eCustomer.reachedCheckouts
+		eStore.notProcessedCustomersClosing.Inc()
+		return
+	}
 
-		if eStore.hasFloorManager {
-			// When the store has a floor manager the floor manager will drive the customers
-			// to the checkout with less deep queue.
-			checkout = getCheckoutWithShorterQueue(eStore, nextCustomerNumberOfProducts)
-		} else {
-			// Otherwise we get a random checkout
-			checkout = getCheckoutRandomly(eStore, nextCustomerNumberOfProducts)
+	// Customers that like the kiosks try them first, see selfcheckout.go. Otherwise the
+	// store's routing strategy picks the checkout, see routing.go.
+	checkout := chooseSelfCheckout(eStore, eCustomer)
+	if checkout != nil {
+		eCustomer.routingPolicy = "self-checkout"
+	} else {
+		checkout = eStore.checkoutSelector.SelectCheckout(eStore, eCustomer)
+		eCustomer.routingPolicy = eStore.checkoutSelector.Name()
+		checkout = redirectToExpress(eStore, eCustomer, checkout)
+	}
+
+	if checkout == nil {
+		// No till will take the customer, not now and not later today.
+		sim.logf("Customer %4d leaves the store, no checkout will take them\n", eCustomer.customerId)
+		eCustomer.arrivalDecision = arrivalLeft
+		eCustomer.leftQueue = true
+		eCustomer.outcome = outcomeBalked
+		eCustomer.departureTime = eCustomer.reachedCheckouts
+		eStore.notProcessedCustomersQueuingDeep.Inc()
+		return
+	}
+
+	// A look at the line decides if the customer joins it, tries another one or leaves.
+	if acceptsQueue(eStore, checkout, eCustomer) {
+		eCustomer.arrivalDecision = arrivalJoined
+		eStore.arrivalsJoined.Inc()
+	} else if otherCheckout := findAcceptableCheckout(eStore, eCustomer); otherCheckout != nil {
+		sim.logf("Customer %4d does not like the line at Checkout %2d and goes to Checkout %2d\n",
+			eCustomer.customerId, checkout.checkoutId, otherCheckout.checkoutId)
+		checkout = otherCheckout
+		eCustomer.arrivalDecision = arrivalOtherLane
+		eStore.arrivalsOtherLane.Inc()
+	} else {
+		// Customer leaving because queue was deeper than what he wants to wait.
+		sim.logf("Customer %4d leaves the store, the line at Checkout %2d is too long\n",
+			eCustomer.customerId, checkout.checkoutId)
+		eCustomer.checkoutId = checkout.checkoutId
+		eCustomer.arrivalDecision = arrivalLeft
+		eCustomer.leftQueue = true
+		eCustomer.outcome = outcomeBalked
+		eCustomer.departureTime = eCustomer.reachedCheckouts
+		eStore.notProcessedCustomersQueuingDeep.Inc()
+		return
+	}
+
+	eCustomer.checkoutId = checkout.checkoutId
+	checkout.currentDeep.Inc()
+	queueIndex := getQueueIndex(eStore, checkout)
+
+	fmt.Fprintln(sim.out, "Queue: "+queueIndex+" has length: "+strconv.Itoa(checkout.currentDeep.Value()))
+	eCustomer.queueTimeStart, _ = sim.clock.getSimWorldCurrentTime()
+	joinLine(eStore, checkout, eCustomer)
+	scheduleRenege(eStore, checkout, eCustomer)
+}
+
+// scheduleRenege makes the customer leave the line the moment their patience runs out,
+// unless a checkout took them first and cancelled the event.
+func scheduleRenege(eStore *store, eCheckout *checkout, eCustomer *customer) {
+	sim := eStore.sim
+	queue := sim.queues[getQueueIndex(eStore, eCheckout)]
+
+	// After switching lines the patience left is what is left since first joining a line.
+	patienceLeft := float64(eCustomer.queueTimeStart+eCustomer.maxQueueTimeSeconds) - sim.engine.now
+	eCustomer.renegeEvent = sim.engine.schedule(patienceLeft, func() {
+		// Customer leaving because waiting longer than what he wants to wait.
+		if !queue.remove(eCustomer) {
+			return
 		}
+		eCustomer.leftQueue = true
+		eCustomer.outcome = outcomeReneged
+		eCustomer.queueTimeEnd, _ = sim.clock.getSimWorldCurrentTime()
+		eCustomer.queueTimeSeconds = sim.clock.diffInSeconds(eCustomer.queueTimeStart, eCustomer.queueTimeEnd)
+		eCustomer.departureTime = eCustomer.queueTimeEnd
+		eCheckout.currentDeep.Dec()
+		eStore.notProcessedCustomersQueuingTime.Inc()
+		sim.logf("Customer %4d gave up queuing at Checkout %2d after %d minutes\n",
+			eCustomer.customerId, eCheckout.checkoutId, eCustomer.queueTimeSeconds/60)
+		lineGotShorter(eStore, eCheckout)
+	})
+}
 
-		checkout.currentDeep.Inc()
-		queueIndex := getQueueIndex(eStore, checkout)
+// closeStore closes the doors at openingHoursTo. Depending on the closing policy the
+// customers still queuing or shopping are either served or sent home, after that every
+// checkout shuts as soon as its line is empty.
+func closeStore(eStore *store) {
+	sim := eStore.sim
+	sim.sleep(float64(eStore.openingHoursTo*3600) - sim.engine.now)
+	eStore.doorsClosed = true
+
+	if eStore.closingPolicy != closingPolicyTurnAway {
+		sim.logf("Store %d is closing, customers still shopping or queuing will be served.\n", eStore.storeId)
+		closeLinesAfterLastShopper(eStore)
+		return
+	}
 
-		fmt.Println("Queue: " + queueIndex + " has length: " + strconv.Itoa(checkout.currentDeep.Value()))
-		eStore.customers[kCustomer].queueTimeStart, _ = dualClock.getSimWorldCurrentTime()
-		queues[queueIndex] <- eStore.customers[kCustomer]
-		i++
+	// Those still in the aisles are sent home when they get to the checkouts, see reachCheckouts.
+	sim.logf("Store %d is closing, queuing customers are turned away.\n", eStore.storeId)
+	for _, queue := range storeQueues(sim, eStore) {
+		for _, eCustomer := range queue.drain() {
+			sim.engine.cancel(eCustomer.renegeEvent)
+			eCustomer.leftQueue = true
+			eCustomer.outcome = outcomeTurnedAway
+			eCustomer.queueTimeEnd, _ = sim.clock.getSimWorldCurrentTime()
+			eCustomer.queueTimeSeconds = sim.clock.diffInSeconds(eCustomer.queueTimeStart, eCustomer.queueTimeEnd)
+			eCustomer.departureTime = eCustomer.queueTimeEnd
+			eStore.checkouts["checkout"+strconv.Itoa(eCustomer.checkoutId)].currentDeep.Dec()
+			eStore.notProcessedCustomersClosing.Inc()
+		}
 	}
+	closeLinesAfterLastShopper(eStore)
+}
 
+// closeLinesAfterLastShopper closes the lines once the doors are closed and nobody is
+// left in the aisles, so the checkouts can shut when they have served everybody.
+func closeLinesAfterLastShopper(eStore *store) {
+	if !eStore.doorsClosed || eStore.customersShopping > 0 || eStore.linesClosed {
+		return
+	}
+	eStore.linesClosed = true
+	for _, queue := range storeQueues(eStore.sim, eStore) {
+		queue.close()
+	}
 }
+
 func getQueueIndex(eStore *store, eCheckout *checkout) string {
 	return "store_" + strconv.Itoa(eStore.storeId) + "_checkout_" + strconv.Itoa(eCheckout.checkoutId)
 }
 
-var ch chan int
 var defaultScenarios = map[string]string{}
 
 func main() {
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+func registerBuiltInScenarios() {
 	// Scenario 1 Settings - Override predefined settings by scenario
 	defaultScenarios["SCENARIO1_[store1]numberOfCustomers"] = "300-400"
 	defaultScenarios["SCENARIO1_[store1]numberOfProducts"] = "1-120"
@@ -473,67 +760,93 @@ func main() {
 	defaultScenarios["SCENARIO3_[store1][checkout7]maxItems"] = "0"
 	defaultScenarios["SCENARIO3_[store1][checkout8]maxItems"] = "0"
 	defaultScenarios["SCENARIO3_[store1][checkout9]maxItems"] = "10"
+}
 
-	var lastStringReader string
+// setupSimulation asks for every setting (or takes it from the scenario) and builds
+// the stores, their checkouts and their customers for one run.
+func setupSimulation(seed int64) (*simulation, error) {
 	var stores = map[string]*store{}
-	rand.Seed(time.Now().UnixNano())
-	lastStringReader = readFromConsole(
-		"Do you want to use all defaults settings? [Y/N/scenario1/scenario2/scenario3]:",
+	answersGiven = map[string]string{}
+	defaultSettingsCode := readFromConsole(
+		"Do you want to use all defaults settings? ["+strings.Join(scenarioNames(), "/")+"]:",
 		true,
 		"Y",
 		"Y",
 		"defaultSettingsCode")
 
-	defaultSettingsCode := lastStringReader
+	if !isKnownScenario(defaultSettingsCode) {
+		return nil, fmt.Errorf("unknown scenario %q, expected one of %s",
+			defaultSettingsCode, strings.Join(scenarioNames(), ", "))
+	}
 	////Value of One hour In seconds
-	lastStringReader = readFromConsole(
+	oneHourIsInSeconds, err := readInt(
 		"How many seconds in the simulation will be one hour in real life? [1] means: 1 second is 1 hour in real life.",
-		true,
 		"1",
 		defaultSettingsCode,
-		"oneHourIsInSeconds")
-	oneHourIsInSeconds, _ := strconv.Atoi(lastStringReader)
+		"oneHourIsInSeconds",
+		1, 3600)
+	if err != nil {
+		return nil, err
+	}
+
+	//// Simulation mode
+	simulationMode, err := readChoice(
+		"Run as a discrete-event simulation or in real time? [E/R]. E finishes the day instantly, R sleeps for live demos.",
+		"E",
+		defaultSettingsCode,
+		"simulationMode",
+		"E", "R")
+	if err != nil {
+		return nil, err
+	}
+	realTime := simulationMode == "R"
 
-	dualClock = dualTimeClock{secondsAreOneHour: oneHourIsInSeconds}
-	if dualClock.secondsAreOneHour > 60 {
-		fmt.Println("Warning simulation may be slow..")
+	dualClock := dualTimeClock{secondsAreOneHour: oneHourIsInSeconds}
+	if realTime && dualClock.secondsAreOneHour > 60 {
+		fmt.Fprintln(console, "Warning simulation may be slow..")
 	}
 
 	//// Number of stores
-	lastStringReader = readFromConsole(
+	numberOfStores, err := readInt(
 		"How many stores do you want to simulate?",
-		true,
 		"1",
 		defaultSettingsCode,
-		"numberOfStores")
-
-	numberOfStores, _ := strconv.Atoi(lastStringReader)
+		"numberOfStores",
+		1, 100)
+	if err != nil {
+		return nil, err
+	}
 
 	//// Define settings by each store
 	for iStore := 1; iStore <= numberOfStores; iStore++ {
+		rng := newRandomStreams(seed, iStore)
 
 		//// Opening Hours
-		openingHours := readFromConsole(
+		openingHours, err := readOpeningHours(
 			"[Store "+strconv.Itoa(iStore)+"] Enter opening hours from-to, [8-22]:",
-			true,
 			"8-22",
 			defaultSettingsCode,
 			"[store"+strconv.Itoa(iStore)+"]openingHours")
+		if err != nil {
+			return nil, err
+		}
 		//// busy ranges, ask based on opening times.
-		openingHoursParts := strings.Split(openingHours, "-")
-		openingHoursFrom, _ := strconv.Atoi(openingHoursParts[0])
-		openingHoursTo, _ := strconv.Atoi(openingHoursParts[1])
+		openingHoursFrom := openingHours.from
+		openingHoursTo := openingHours.to
 
 		var busyRanges = map[string]busyRange{}
 
 		for iBusyRange := openingHoursFrom; iBusyRange <= openingHoursTo; iBusyRange++ {
-			lastStringReader := readFromConsole(
+			busyLevel, err := readChoice(
 				"[Store "+strconv.Itoa(iStore)+"] How busy will this store be at: ["+strconv.Itoa(iBusyRange)+":00]",
-				true,
 				"lb",
 				defaultSettingsCode,
-				"[store"+strconv.Itoa(iStore)+"]busyRange_"+strconv.Itoa(iBusyRange))
-			selectedBusyRange := busyRangeOptions[lastStringReader]
+				"[store"+strconv.Itoa(iStore)+"]busyRange_"+strconv.Itoa(iBusyRange),
+				"Q", "LB", "B")
+			if err != nil {
+				return nil, err
+			}
+			selectedBusyRange := busyRangeOptions[busyLevel]
 
 			busyRanges["busyRange_"+strconv.Itoa(iBusyRange)] = busyRange{
 				fromHour:         iBusyRange,
@@ -543,118 +856,258 @@ func main() {
 		}
 
 		//// Weather
-		lastStringReader := readFromConsole(
+		weatherCode, err := readChoice(
 			"Set weather conditions: type: B or G or E. Where B means bad, G means good and E means excellent:",
-			true,
 			"G",
 			defaultSettingsCode,
-			"[store"+strconv.Itoa(iStore)+"]weather")
+			"[store"+strconv.Itoa(iStore)+"]weather",
+			"B", "G", "E")
+		if err != nil {
+			return nil, err
+		}
 
-		weather := weatherOptions[lastStringReader]
+		weather := weatherOptions[weatherCode]
 		//// Floor manager
-		lastStringReader = readFromConsole(
+		floorManager, err := readChoice(
 			"[Store "+strconv.Itoa(iStore)+"] Do you want to enable a Floor Manager for this store? [Y/n]:",
-			true,
 			"Y",
 			defaultSettingsCode,
-			"[store"+strconv.Itoa(iStore)+"]isFloorManager")
-		isFloorManager := false
-		if lastStringReader == "Y" {
-			isFloorManager = true
+			"[store"+strconv.Itoa(iStore)+"]isFloorManager",
+			"Y", "N")
+		if err != nil {
+			return nil, err
+		}
+		isFloorManager := floorManager == "Y"
+		//// Routing strategy
+		// When the store has a floor manager the floor manager will drive the customers
+		// to the checkout with less deep queue, otherwise they pick a checkout at random.
+		defaultRoutingStrategy := randomSelector{}.Name()
+		if isFloorManager {
+			defaultRoutingStrategy = shortestQueueSelector{}.Name()
+		}
+		routingStrategy, err := readChoice(
+			"[Store "+strconv.Itoa(iStore)+"] How do customers choose a checkout? ["+
+				strings.Join(checkoutSelectorNames(), "/")+"]:",
+			strings.ToUpper(defaultRoutingStrategy),
+			defaultSettingsCode,
+			"[store"+strconv.Itoa(iStore)+"]routingStrategy",
+			upperAll(checkoutSelectorNames())...)
+		if err != nil {
+			return nil, err
+		}
+		//// Closing policy
+		closingPolicy, err := readChoice(
+			"[Store "+strconv.Itoa(iStore)+"] At closing time, serve the customers still queuing or turn them away? [SERVE/turn-away]:",
+			closingPolicyServe,
+			defaultSettingsCode,
+			"[store"+strconv.Itoa(iStore)+"]closingPolicy",
+			closingPolicyServe, closingPolicyTurnAway)
+		if err != nil {
+			return nil, err
+		}
+		//// Queue topology
+		queueTopology, err := readChoice(
+			"[Store "+strconv.Itoa(iStore)+"] How are the lines laid out? One per checkout, one shared line, or "+
+				"shared plus an express line [PER-LANE/shared/hybrid]:",
+			topologyPerLane,
+			defaultSettingsCode,
+			"[store"+strconv.Itoa(iStore)+"]queueTopology",
+			topologyPerLane, topologyShared, topologyHybrid)
+		if err != nil {
+			return nil, err
+		}
+		//// Jockeying
+		jockeyingAnswer, err := readChoice(
+			"[Store "+strconv.Itoa(iStore)+"] Do customers move to a neighbouring line when it gets shorter? [y/N]:",
+			"N",
+			defaultSettingsCode,
+			"[store"+strconv.Itoa(iStore)+"]jockeying",
+			"Y", "N")
+		if err != nil {
+			return nil, err
+		}
+		isJockeying := jockeyingAnswer == "Y"
+		jockeyThreshold := 0
+		jockeyPropensity := floatRange{}
+		if isJockeying {
+			jockeyThreshold, err = readInt(
+				"[Store "+strconv.Itoa(iStore)+"] How many people shorter does the next line have to be? [2] ",
+				"2",
+				defaultSettingsCode,
+				"[store"+strconv.Itoa(iStore)+"]jockeyThreshold",
+				1, 1000)
+			if err != nil {
+				return nil, err
+			}
+			jockeyPropensity, err = readFloatRange(
+				"[Store "+strconv.Itoa(iStore)+"] How likely is a customer to switch when it is? Range response "+
+					"[0.2-0.8] means some customers switch 2 times out of 10, others 8 times out of 10.",
+				"0.2-0.8",
+				defaultSettingsCode,
+				"[store"+strconv.Itoa(iStore)+"]jockeyPropensity",
+				0, 1)
+			if err != nil {
+				return nil, err
+			}
 		}
 		//// number of customers
-		numberOfCustomers := readFromConsole(
+		numberOfCustomers, err := readIntRange(
 			"[Store "+strconv.Itoa(iStore)+"] How many customers do you want to generate? Range response [350-450] "+
-				"means from 350 to 450 customers a day.",
-			true,
+				"means from 350 to 450 customers on a little busy day with good weather.",
 			"350-450",
 			defaultSettingsCode,
-			"[store"+strconv.Itoa(iStore)+"]numberOfCustomers")
+			"[store"+strconv.Itoa(iStore)+"]numberOfCustomers",
+			0, 100000)
+		if err != nil {
+			return nil, err
+		}
 		//// number of products
-		numberOfProducts := readFromConsole(
+		numberOfProducts, err := readIntRange(
 			"[Store "+strconv.Itoa(iStore)+"] How many products do you want to generate per customer? Range "+
 				"response [1-100] means from 1 to 100 products per customer.",
-			true,
 			"1-100",
 			defaultSettingsCode,
-			"[store"+strconv.Itoa(iStore)+"]numberOfProducts")
+			"[store"+strconv.Itoa(iStore)+"]numberOfProducts",
+			1, 10000)
+		if err != nil {
+			return nil, err
+		}
 		//// number of products
-		productProcessTime := readFromConsole(
+		productProcessTime, err := readFloatRange(
 			"[Store "+strconv.Itoa(iStore)+"] How much should it take a product to be scanned? Range response in "+
 				"seconds [0.5-6] means from 0.5 second to 6 seconds per product.",
-			true,
 			"0.5-6",
 			defaultSettingsCode,
-			"[store"+strconv.Itoa(iStore)+"]productProcessTime")
+			"[store"+strconv.Itoa(iStore)+"]productProcessTime",
+			0, 600)
+		if err != nil {
+			return nil, err
+		}
 
 		//// max queue time
-		maxQueueTime := readFromConsole(
+		maxQueueTime, err := readIntRange(
 			"[Store "+strconv.Itoa(iStore)+"] How many minutes will usually a customer be in queue before giving up? "+
 				"Range response in minutes [15-30] means from 15 to 30 minute a person will usually give up",
-			true,
 			"15-30",
 			defaultSettingsCode,
-			"[store"+strconv.Itoa(iStore)+"]maxQueueTime")
+			"[store"+strconv.Itoa(iStore)+"]maxQueueTime",
+			0, 24*60)
+		if err != nil {
+			return nil, err
+		}
 
 		//// max queue customers
-		maxQueueCustomers := readFromConsole(
+		maxQueueCustomers, err := readIntRange(
 			"[Store "+strconv.Itoa(iStore)+"] How deep should usually a queue be for customer to give up? "+
 				"Range response in customer numbers [5-10] means from 5 to 10 customers in queue will make a customer "+
 				"to give up.",
-			true,
 			"5-10",
 			defaultSettingsCode,
-			"[store"+strconv.Itoa(iStore)+"]maxQueueCustomers")
+			"[store"+strconv.Itoa(iStore)+"]maxQueueCustomers",
+			0, 100000)
+		if err != nil {
+			return nil, err
+		}
+
+		//// max queue items
+		maxQueueItems, err := readIntRange(
+			"[Store "+strconv.Itoa(iStore)+"] How many items in the line in front make a customer look for another "+
+				"line or leave? Range response [200-400] means from 200 to 400 items, 0 means customers only count people.",
+			"0",
+			defaultSettingsCode,
+			"[store"+strconv.Itoa(iStore)+"]maxQueueItems",
+			0, 1000000)
+		if err != nil {
+			return nil, err
+		}
+
+		//// shopping time
+		shoppingTimePerItem, err := readFloatRange(
+			"[Store "+strconv.Itoa(iStore)+"] How long does it take a customer to find a product in the aisles? Range "+
+				"response in seconds [15-30] means from 15 to 30 seconds per product, 0 goes straight to the checkouts.",
+			"15-30",
+			defaultSettingsCode,
+			"[store"+strconv.Itoa(iStore)+"]shoppingTimePerItem",
+			0, 3600)
+		if err != nil {
+			return nil, err
+		}
 
 		//// number of checkouts
-		lastStringReader = readFromConsole(
+		numberOfCheckouts, err := readInt(
 			"[Store "+strconv.Itoa(iStore)+"] How many checkouts will this store have? [10] ",
-			true,
 			"10",
 			defaultSettingsCode,
-			"[store"+strconv.Itoa(iStore)+"]numberOfCheckouts")
+			"[store"+strconv.Itoa(iStore)+"]numberOfCheckouts",
+			1, 1000)
+		if err != nil {
+			return nil, err
+		}
 
-		numberOfCheckouts, _ := strconv.Atoi(lastStringReader)
+		//// Time for a new cashier to take over a till
+		handoverTime, err := readFloat(
+			"[Store "+strconv.Itoa(iStore)+"] How many seconds does it take when a till changes cashier? [60] ",
+			"60",
+			defaultSettingsCode,
+			"[store"+strconv.Itoa(iStore)+"]handoverTime",
+			0, 3600)
+		if err != nil {
+			return nil, err
+		}
 
 		var checkouts = map[string]*checkout{}
 
 		//// Define settings by each checkout
 		for iCheckout := 1; iCheckout <= numberOfCheckouts; iCheckout++ {
 			//// Cashier Efficiency
-			lastStringReader = readFromConsole(
+			cashierEfficiency, err := readPositiveFloat(
 				"[Store "+strconv.Itoa(iStore)+"][Checkout "+strconv.Itoa(iCheckout)+"] How efficient is this cashier? [1] Recommended value from 0.1 (Really Slow) to 1.9 (Really Fast) ",
-				true,
 				"1",
 				defaultSettingsCode,
-				"[store"+strconv.Itoa(iStore)+"][checkout"+strconv.Itoa(iCheckout)+"]cashierEfficiency")
-
-			cashierEfficiency, _ := strconv.ParseFloat(lastStringReader, 64)
+				"[store"+strconv.Itoa(iStore)+"][checkout"+strconv.Itoa(iCheckout)+"]cashierEfficiency",
+				10)
+			if err != nil {
+				return nil, err
+			}
 			//// Max Items
-			lastStringReader = readFromConsole(
+			maxItems, err := readInt(
 				"[Store "+strconv.Itoa(iStore)+"][Checkout "+strconv.Itoa(iCheckout)+"] Maximum items for this checkout? 0 means unlimited [0] ",
-				true,
 				"0",
 				defaultSettingsCode,
-				"[store"+strconv.Itoa(iStore)+"][checkout"+strconv.Itoa(iCheckout)+"]maxItems")
-
-			maxItems, _ := strconv.Atoi(lastStringReader)
+				"[store"+strconv.Itoa(iStore)+"][checkout"+strconv.Itoa(iCheckout)+"]maxItems",
+				0, 10000)
+			if err != nil {
+				return nil, err
+			}
 			//// Checkout desirability
-			lastStringReader = readFromConsole(
+			checkoutDesirability, err := readInt(
 				"[Store "+strconv.Itoa(iStore)+"][Checkout "+strconv.Itoa(iCheckout)+"] How desirable will be this checkout in respect to the others "+
 					"based on its location? ",
-				true,
 				strconv.Itoa(iCheckout),
 				defaultSettingsCode,
-				"[store"+strconv.Itoa(iStore)+"][checkout"+strconv.Itoa(iCheckout)+"]checkoutDesirability")
-
-			checkoutDesirability, _ := strconv.Atoi(lastStringReader)
+				"[store"+strconv.Itoa(iStore)+"][checkout"+strconv.Itoa(iCheckout)+"]checkoutDesirability",
+				0, 10000)
+			if err != nil {
+				return nil, err
+			}
+			//// Payments, express hours and accessibility of the lane
+			lane, err := readLanePolicy(iStore, iCheckout, defaultSettingsCode)
+			if err != nil {
+				return nil, err
+			}
+			//// Cashiers, shifts and breaks
+			roster, err := readRoster(iStore, iCheckout, cashierEfficiency, openingHoursTo, defaultSettingsCode)
+			if err != nil {
+				return nil, err
+			}
 
 			checkouts["checkout"+strconv.Itoa(iCheckout)] = &checkout{
 				checkoutId:           iCheckout,
 				cashierEfficiency:    cashierEfficiency,
-				paymentTime:          60,
 				maxItems:             maxItems,
 				checkoutDesirability: checkoutDesirability,
+				roster:               roster,
+				lane:                 lane,
 				currentDeep:          SafeCounter{v: 0},
 				status:               "IDLE",
 				totalItemsCheckedOut: SafeCounter{v: 0},
@@ -662,68 +1115,131 @@ func main() {
 			}
 		}
 
-		numberOfCustomersParts := strings.Split(numberOfCustomers, "-")
-		numberOfCustomersFrom, _ := strconv.Atoi(numberOfCustomersParts[0])
-		numberOfCustomersTo, _ := strconv.Atoi(numberOfCustomersParts[1])
-		numberOfCustomerMax := generateRandomNumber(numberOfCustomersFrom, numberOfCustomersTo)
+		if err := checkRosterCoversClosing(iStore, checkouts); err != nil {
+			return nil, err
+		}
 
-		var customers = map[string]*customer{}
+		//// Floor manager rules and standby tills
+		var floorManagerRules floorManagerSettings
+		if isFloorManager {
+			floorManagerRules, err = readFloorManagerSettings(iStore, defaultSettingsCode)
+			if err != nil {
+				return nil, err
+			}
+			addStandbyTills(checkouts, floorManagerRules)
+		}
+
+		//// Self-service kiosks
+		selfCheckout, err := readSelfCheckoutSettings(iStore, defaultSettingsCode)
+		if err != nil {
+			return nil, err
+		}
+		addKiosks(checkouts, selfCheckout)
+
+		//// Payments
+		payments, err := readPaymentSettings(iStore, defaultSettingsCode)
+		if err != nil {
+			return nil, err
+		}
+
+		//// Customers over the item limit and customers needing an accessible lane
+		lanes, err := readLaneSettings(iStore, defaultSettingsCode)
+		if err != nil {
+			return nil, err
+		}
+
+		//// What the customers buy
+		productCatalogue, err := readCatalogue(iStore, defaultSettingsCode)
+		if err != nil {
+			return nil, err
+		}
 
-		//Apply weather conditions:
-		numberOfCustomersTo = int(float32(numberOfCustomersTo) * weather.factor)
+		//// ID checks, overrides, voids and keying in at the staffed checkouts
+		exceptions, err := readExceptionSettings(iStore, defaultSettingsCode)
+		if err != nil {
+			return nil, err
+		}
 
-		for iCustomer := 0; iCustomer < numberOfCustomerMax; iCustomer++ {
+		//// Wages, prices and margin
+		economics, err := readEconomicsSettings(iStore, productCatalogue != nil, defaultSettingsCode)
+		if err != nil {
+			return nil, err
+		}
+		if err := readCheckoutWages(iStore, checkouts, economics.hourlyWage, defaultSettingsCode); err != nil {
+			return nil, err
+		}
 
-			numberOfProductsParts := strings.Split(numberOfProducts, "-")
-			numberOfProductsFrom, _ := strconv.Atoi(numberOfProductsParts[0])
-			numberOfProductsTo, _ := strconv.Atoi(numberOfProductsParts[1])
+		// numberOfCustomers is a little busy day with good weather, the busy ranges and
+		// the weather turn it into an arrival rate for every hour.
+		customersPerDay := generateRandomNumber(rng.arrivals, numberOfCustomers.from, numberOfCustomers.to)
+		expectedArrivals := hourlyArrivalRates(openingHours, busyRanges, weather, customersPerDay)
+		arrivalTimes := generateArrivalTimes(rng.arrivals, openingHours, expectedArrivals)
+
+		var customers = map[string]*customer{}
+
+		for iCustomer, arrivalTime := range arrivalTimes {
 
 			var products = map[string]product{}
-			numberOfProductsForCustomer := generateRandomNumber(numberOfProductsFrom, numberOfProductsTo)
+			numberOfProductsForCustomer := generateRandomNumber(rng.baskets, numberOfProducts.from, numberOfProducts.to)
 			for iProduct := 1; iProduct <= numberOfProductsForCustomer; iProduct++ {
 
-				productProcessTimeParts := strings.Split(productProcessTime, "-")
-				productProcessTimeFrom, _ := strconv.ParseFloat(productProcessTimeParts[0], 64)
-				productProcessTimeTo, _ := strconv.ParseFloat(productProcessTimeParts[1], 64)
-
 				// we gave the user the example/default of 0.5 - 10s
 				// for practicality, let's only deal with tenths of second for scanning times
 				// rand only deals with ints so we need to multiply by 10, then convert to an int
 				// then divide by 10 to get tenths of a second in a sensible range for
 				// scanning groceries
-				processTimeCalc := float64(generateRandomNumber(
-					int(10*productProcessTimeFrom), int(10*productProcessTimeTo)))
+				processTimeCalc := float64(generateRandomNumber(rng.scanTimes,
+					int(10*productProcessTime.from), int(10*productProcessTime.to)))
 				processTimeCalc = processTimeCalc / 10.0
-				products["product"+strconv.Itoa(iProduct)] = product{
+				eProduct := product{
 					productId:         iProduct,
 					processTimeSecond: processTimeCalc,
 				}
+				if productCatalogue != nil {
+					productCatalogue.pick(rng.catalogue, &eProduct)
+				}
+				products["product"+strconv.Itoa(iProduct)] = eProduct
 			}
 
 			var maxQueueTimeSeconds int64
+			var maxQueueItemsForCustomer int
 
-			maxQueueTimeParts := strings.Split(maxQueueTime, "-")
-			maxQueueTimeFrom, _ := strconv.Atoi(maxQueueTimeParts[0])
-			maxQueueTimeTo, _ := strconv.Atoi(maxQueueTimeParts[1])
+			maxQueueTimeSeconds = int64(generateRandomNumber(rng.patience, maxQueueTime.from, maxQueueTime.to) * 60)
 
-			maxQueueTimeSeconds = int64(generateRandomNumber(maxQueueTimeFrom, maxQueueTimeTo) * 60)
+			var jockeyPropensityForCustomer float64
+			if isJockeying {
+				jockeyPropensityForCustomer = jockeyPropensity.from +
+					rng.jockeying.Float64()*(jockeyPropensity.to-jockeyPropensity.from)
+			}
 
-			maxQueueCustomersParts := strings.Split(maxQueueCustomers, "-")
-			maxQueueCustomersFrom, _ := strconv.Atoi(maxQueueCustomersParts[0])
-			maxQueueCustomersTo, _ := strconv.Atoi(maxQueueCustomersParts[1])
+			if maxQueueItems.to > 0 {
+				maxQueueItemsForCustomer = generateRandomNumber(rng.patience, maxQueueItems.from, maxQueueItems.to)
+			}
 
-			customers["customer"+strconv.Itoa(iCustomer)] = &customer{
+			eCustomer := &customer{
 				customerId:          iCustomer,
 				items:               len(products),
 				checkoutId:          0,
 				queueTimeSeconds:    0,
 				maxQueueTimeSeconds: maxQueueTimeSeconds,
-				maxQueueCustomers:   generateRandomNumber(maxQueueCustomersFrom, maxQueueCustomersTo),
+				scheduledArrival:    arrivalTime,
+				maxQueueCustomers:   generateRandomNumber(rng.patience, maxQueueCustomers.from, maxQueueCustomers.to),
+				maxQueueItems:       maxQueueItemsForCustomer,
+				jockeyPropensity:    jockeyPropensityForCustomer,
+				shoppingSeconds: shoppingDuration(rng.shopping, shoppingTimePerItem, len(products),
+					busyRanges, arrivalTime),
+				prefersSelfCheckout: selfCheckout.kiosks > 0 && rng.selfCheckout.Float64() < selfCheckout.preference,
+				paymentMethod:       payments.pickPaymentMethod(rng.payments),
+				cheatsItemLimit:     rng.lanes.Float64() < lanes.cheatChance,
+				needsAccessibility:  rng.lanes.Float64() < lanes.accessibilityNeed,
 				purchaseComplete:    false,
 				leftQueue:           false,
 				checkoutTime:        0,
 				products:            products,
+				rng:                 rng.forCustomer(iCustomer),
 			}
+			eCustomer.basketPrice = economics.priceBasket(rng.prices, eCustomer)
+			customers["customer"+strconv.Itoa(iCustomer)] = eCustomer
 		}
 
 		stores["store"+strconv.Itoa(iStore)] = &store{
@@ -731,97 +1247,70 @@ func main() {
 			checkouts:          checkouts,
 			busyRanges:         busyRanges,
 			weather:            weather,
+			openingHours:       openingHours,
 			openingHoursFrom:   openingHoursFrom,
 			openingHoursTo:     openingHoursTo,
-			totalCustomers:     generateRandomNumber(numberOfCustomersFrom, numberOfCustomersTo),
+			totalCustomers:     len(arrivalTimes),
+			expectedArrivals:   expectedArrivals,
 			hasFloorManager:    isFloorManager,
+			floorManager:       floorManagerRules,
+			closingPolicy:      closingPolicy,
+			queueTopology:      queueTopology,
+			jockeying:          isJockeying,
+			jockeyThreshold:    jockeyThreshold,
+			handoverSeconds:    handoverTime,
+			selfCheckout:       selfCheckout,
+			payments:           payments,
+			lanes:              lanes,
+			catalogue:          productCatalogue,
+			exceptions:         exceptions,
+			economics:          economics,
+			supervisor:         newHelper(),
+			attendant:          newHelper(),
+			checkoutSelector:   checkoutSelectors[strings.ToLower(routingStrategy)],
+			productProcessTime: productProcessTime,
+			rng:                rng,
 			customers:          customers,
 			processedCustomers: SafeCounter{v: 0},
 		}
 	}
 	var earliestStoreOpening int = 23
-	for kStore, eStore := range stores {
-		fmt.Println(kStore)
-		fmt.Printf("%s opens at %d.\n", kStore, eStore.openingHoursFrom)
+	for _, kStore := range sortedStoreKeys(stores) {
+		eStore := stores[kStore]
+		fmt.Fprintf(console, "%s opens at %d.\n", kStore, eStore.openingHoursFrom)
 		if eStore.openingHoursFrom < earliestStoreOpening {
 			earliestStoreOpening = eStore.openingHoursFrom
 		}
 	}
-	dualClock.initRealWorldStartTime()
 	dualClock.initSimWorldDayClock(earliestStoreOpening)
-	if false {
-		fmt.Println("START Sanity Check of Dual Clock")
-		realWorldStartTimeTempString := time.Now()
-		realWorldStartTimeTempSeconds := time.Now().Unix()
-		simWorldStartTimeTempInt, simWorldStartTimeTempString := dualClock.getSimWorldCurrentTime()
-		fmt.Printf("Realworld start time is %s.\n", realWorldStartTimeTempString)
-		fmt.Printf("Simworld start time is %s.\n", simWorldStartTimeTempString)
-		fmt.Printf("%d Real World seconds = 1 hour in the sim world\n", dualClock.secondsAreOneHour)
-		fmt.Printf("Sleep %d seconds in Real World\n", dualClock.secondsAreOneHour)
-		time.Sleep(time.Duration(dualClock.secondsAreOneHour) * time.Second)
-		fmt.Printf("Realworld elapsed time is %d.\n", time.Now().Unix()-realWorldStartTimeTempSeconds)
-		simWorldCurrentTimeInt, simWorldCurrentTimeString := dualClock.getSimWorldCurrentTime()
-		fmt.Printf("Simworld elapsed time is %d.\n", simWorldCurrentTimeInt-simWorldStartTimeTempInt)
-		fmt.Printf("Simworld current time is %s.\n", simWorldCurrentTimeString)
-		fmt.Println("END Sanity Check of Dual Clock")
-	}
-
-	totalCheckouts := 0
-	allCustomerToBeProcessed := 0
-	ch = make(chan int)
-
-	for kStore, eStore := range stores {
-		fmt.Println(kStore)
 
-		allCustomerToBeProcessed = allCustomerToBeProcessed + len(eStore.customers)
-		for kCheckout, eCheckout := range eStore.checkouts {
-			fmt.Println(kCheckout)
-			index := getQueueIndex(stores[kStore], eCheckout)
-			queues[index] = make(chan *customer)
-			go openCheckout(eStore, kCheckout, eCheckout)
-			totalCheckouts++
-		}
-
-	}
-
-	for _, eStore := range stores {
-		go customerSpawning(eStore)
-	}
-
-	totalProcessedCustomers := SafeCounter{v: 0}
-
-	for {
-		newCustomerProcessed := <-ch
-
-		if newCustomerProcessed > 0 {
-			totalProcessedCustomers.Inc()
-		}
-
-		if totalProcessedCustomers.Value() == allCustomerToBeProcessed {
-			close(ch)
-			break
-		}
-	}
+	return newSimulation(&dualClock, realTime, stores), nil
+}
 
-	for kStore := range stores {
+// printSummary is the end of day summary for every store and checkout.
+func printSummary(out io.Writer, stores map[string]*store) {
+	for _, kStore := range sortedStoreKeys(stores) {
 		eStore := stores[kStore]
 
-		fmt.Println("---Store: " + kStore + ", Customer processed: " + strconv.Itoa(eStore.processedCustomers.Value()))
-		fmt.Println("---Store: " + kStore + ", Customer Left(Queuing Time): " + strconv.Itoa(eStore.notProcessedCustomersQueuingTime.Value()))
-		fmt.Println("---Store: " + kStore + ", Customer Left(Queue Deep): " + strconv.Itoa(eStore.notProcessedCustomersQueuingDeep.Value()))
+		fmt.Fprintln(out, "---Store: "+kStore+", Customer processed: "+strconv.Itoa(eStore.processedCustomers.Value()))
+		fmt.Fprintln(out, "---Store: "+kStore+", Customer Left(Queuing Time): "+strconv.Itoa(eStore.notProcessedCustomersQueuingTime.Value()))
+		fmt.Fprintln(out, "---Store: "+kStore+", Customer Left(Queue Deep): "+strconv.Itoa(eStore.notProcessedCustomersQueuingDeep.Value()))
+		fmt.Fprintln(out, "---Store: "+kStore+", Customer Left(Closing Time): "+strconv.Itoa(eStore.notProcessedCustomersClosing.Value()))
+		fmt.Fprintln(out, "---Store: "+kStore+", Customer Went To Another Line: "+strconv.Itoa(eStore.arrivalsOtherLane.Value()))
 
-		for kCheckout := range eStore.checkouts {
-			out := eStore.checkouts[kCheckout]
+		for _, kCheckout := range sortedCheckoutKeys(eStore) {
+			eCheckout := eStore.checkouts[kCheckout]
 
-			labelCheckout := kCheckout
+			labelCheckout := eCheckout.label()
 
-			if out.maxItems > 0 {
-				labelCheckout = labelCheckout + " (max " + strconv.Itoa(out.maxItems) + " items)"
+			if eCheckout.maxItems > 0 {
+				labelCheckout = labelCheckout + " (max " + strconv.Itoa(eCheckout.maxItems) + " items)"
 			}
 
-			fmt.Println("---Checkout: " + labelCheckout + ", Customers processed: " + strconv.Itoa(
-				out.totalCustomersServed.Value()) + ", Products processed: " + strconv.Itoa(
-				out.totalItemsCheckedOut.Value()))
+			fmt.Fprintln(out, "---Checkout: "+labelCheckout+", Customers processed: "+strconv.Itoa(
+				eCheckout.totalCustomersServed.Value())+", Products processed: "+strconv.Itoa(
+				eCheckout.totalItemsCheckedOut.Value()))
 		}
+		printEconomics(out, kStore, eStore)
 	}
 }
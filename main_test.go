@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+// runTestDay runs a day of the default store with some of the answers changed, the way
+// the commands build it, and returns the simulation to look at what happened.
+func runTestDay(t *testing.T, seed int64, answers map[string]string) *simulation {
+	t.Helper()
+	savedOverrides, savedAnswers := promptOverrides, answersGiven
+	savedInteractive, savedConsole := interactive, console
+	t.Cleanup(func() {
+		promptOverrides, answersGiven = savedOverrides, savedAnswers
+		interactive, console = savedInteractive, savedConsole
+	})
+
+	promptOverrides = map[string]string{
+		"defaultSettingsCode": "Y",
+		"isFloorManager":      "N",
+		"selfCheckouts":       "0",
+	}
+	for code, text := range answers {
+		promptOverrides[code] = text
+	}
+	interactive, console = false, io.Discard
+
+	sim, err := setupSimulation(seed)
+	if err != nil {
+		t.Fatalf("setupSimulation: %v", err)
+	}
+	sim.out = io.Discard
+	if err := sim.run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	return sim
+}
+
+func TestClosingTime(t *testing.T) {
+	// One till for a busy morning, and nobody gives up, so there is a line at closing.
+	busy := map[string]string{
+		"openingHours":      "9-12",
+		"busyRange":         "B",
+		"numberOfCustomers": "300-300",
+		"numberOfCheckouts": "1",
+		"maxQueueTime":      "1440-1440",
+		"maxQueueCustomers": "100000-100000",
+	}
+	const closing = 12 * 3600
+
+	for _, policy := range []string{closingPolicyServe, closingPolicyTurnAway} {
+		answers := map[string]string{"closingPolicy": policy}
+		for code, text := range busy {
+			answers[code] = text
+		}
+		eStore := runTestDay(t, 1, answers).stores["store1"]
+
+		served, turnedAway, servedAfterClosing := 0, 0, 0
+		for _, eCustomer := range sortedCustomers(eStore) {
+			switch eCustomer.outcome {
+			case outcomeServed:
+				served++
+				if eCustomer.checkoutTimeStart >= closing {
+					servedAfterClosing++
+				}
+			case outcomeTurnedAway:
+				turnedAway++
+				if eCustomer.departureTime != closing && eCustomer.departureTime != eCustomer.reachedCheckouts {
+					t.Errorf("%s: customer %d turned away at %s, not at closing or on reaching the checkouts",
+						policy, eCustomer.customerId, formatSimTime(eCustomer.departureTime))
+				}
+			default:
+				t.Errorf("%s: customer %d ended the day %q", policy, eCustomer.customerId, eCustomer.outcome)
+			}
+			if policy == closingPolicyTurnAway && eCustomer.arrivalDecision == arrivalJoined &&
+				eCustomer.queueTimeStart >= closing {
+				t.Errorf("%s: customer %d joined a line at %s, after closing",
+					policy, eCustomer.customerId, formatSimTime(eCustomer.queueTimeStart))
+			}
+		}
+
+		switch policy {
+		case closingPolicyServe:
+			if turnedAway > 0 || servedAfterClosing == 0 {
+				t.Errorf("%s: %d turned away and %d served after closing, want none turned away and the line drained",
+					policy, turnedAway, servedAfterClosing)
+			}
+		case closingPolicyTurnAway:
+			if turnedAway == 0 || served == 0 {
+				t.Errorf("%s: %d served and %d turned away, want both", policy, served, turnedAway)
+			}
+		}
+		for _, kCheckout := range sortedCheckoutKeys(eStore) {
+			if eCheckout := eStore.checkouts[kCheckout]; eCheckout.running || eCheckout.currentDeep.Value() != 0 {
+				t.Errorf("%s: %s is still open with %d customers", policy, kCheckout, eCheckout.currentDeep.Value())
+			}
+		}
+	}
+}
+
+func TestRenegeWhenPatienceRunsOut(t *testing.T) {
+	// Everyone waits five minutes at most for the only till.
+	eStore := runTestDay(t, 2, map[string]string{
+		"openingHours":      "9-12",
+		"busyRange":         "B",
+		"numberOfCheckouts": "1",
+		"maxQueueTime":      "5-5",
+		"maxQueueCustomers": "100000-100000",
+	}).stores["store1"]
+	const patience = 5 * 60
+
+	reneged := 0
+	for _, eCustomer := range sortedCustomers(eStore) {
+		switch eCustomer.outcome {
+		case outcomeReneged:
+			reneged++
+			if eCustomer.queueTimeSeconds != patience || eCustomer.departureTime != eCustomer.queueTimeStart+patience {
+				t.Errorf("customer %d joined at %s and reneged at %s, want %s",
+					eCustomer.customerId, formatSimTime(eCustomer.queueTimeStart),
+					formatSimTime(eCustomer.departureTime), formatSimTime(eCustomer.queueTimeStart+patience))
+			}
+			if eCustomer.checkoutTimeStart != 0 {
+				t.Errorf("customer %d reneged after being served", eCustomer.customerId)
+			}
+		case outcomeServed:
+			if waited := eCustomer.checkoutTimeStart - eCustomer.queueTimeStart; waited > patience {
+				t.Errorf("customer %d was served after waiting %ds, longer than their patience", eCustomer.customerId, waited)
+			}
+		}
+	}
+	if reneged == 0 {
+		t.Errorf("nobody reneged at a single till with five minutes of patience")
+	}
+	for queueIndex, queue := range storeQueues(eStore.sim, eStore) {
+		if queue.len() != 0 {
+			t.Errorf("queue %d still has %d customers at the end of the day", queueIndex, queue.len())
+		}
+	}
+	if eCheckout := eStore.checkouts["checkout1"]; eCheckout.currentDeep.Value() != 0 {
+		t.Errorf("checkout1 counts %d customers in its line at the end of the day", eCheckout.currentDeep.Value())
+	}
+}
+
+func TestBalkAtLongLines(t *testing.T) {
+	// Nobody joins a line with three customers in it, and nobody gives up once in one.
+	const maxQueueCustomers = 3
+	eStore := runTestDay(t, 3, map[string]string{
+		"openingHours":      "9-12",
+		"busyRange":         "B",
+		"numberOfCheckouts": "2",
+		"maxQueueTime":      "1440-1440",
+		"maxQueueCustomers": "3-3",
+	}).stores["store1"]
+
+	decisions := map[string]int{}
+	for _, eCustomer := range sortedCustomers(eStore) {
+		decisions[eCustomer.arrivalDecision]++
+		if eCustomer.outcome != outcomeBalked {
+			continue
+		}
+		if eCustomer.arrivalDecision != arrivalLeft || eCustomer.queueTimeStart != 0 || eCustomer.checkoutTimeStart != 0 {
+			t.Errorf("customer %d balked after %q, queuing from %s", eCustomer.customerId,
+				eCustomer.arrivalDecision, formatSimTime(eCustomer.queueTimeStart))
+		}
+		if eCustomer.departureTime != eCustomer.reachedCheckouts {
+			t.Errorf("customer %d reached the checkouts at %s and balked at %s", eCustomer.customerId,
+				formatSimTime(eCustomer.reachedCheckouts), formatSimTime(eCustomer.departureTime))
+		}
+	}
+	for _, decision := range []string{arrivalJoined, arrivalOtherLane, arrivalLeft} {
+		if decisions[decision] == 0 {
+			t.Errorf("no customer decided %q at the checkouts, decisions %v", decision, decisions)
+		}
+	}
+	if got := eStore.notProcessedCustomersQueuingDeep.Value(); got != decisions[arrivalLeft] {
+		t.Errorf("%d customers counted as leaving at a long line, want %d", got, decisions[arrivalLeft])
+	}
+	for i, queue := range storeQueues(eStore.sim, eStore) {
+		if queue.maxLength > maxQueueCustomers {
+			t.Errorf("queue %d grew to %d customers, want at most %d", i, queue.maxLength, maxQueueCustomers)
+		}
+	}
+}
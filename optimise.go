@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceTarget is what the optimiser has to reach in every store: waitShare percent of
+// the customers at the checkouts were served within waitMinutes, and at most maxAbandonment percent of
+// the customers left without paying. A negative share or percentage is not a target.
+type serviceTarget struct {
+	waitShare      float64
+	waitMinutes    float64
+	maxAbandonment float64
+}
+
+// parseServiceTarget reads "wait:90:5" (90% wait under 5 minutes), "abandon:2" (under 2%
+// leave without paying) or both, separated by a comma.
+func parseServiceTarget(text string) (serviceTarget, error) {
+	target := serviceTarget{waitShare: -1, maxAbandonment: -1}
+	for _, part := range strings.Split(text, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ":")
+		var err error
+		switch {
+		case strings.EqualFold(fields[0], "wait") && len(fields) == 3:
+			if target.waitShare, err = parseFloat(fields[1], 0, 100); err != nil {
+				return target, fmt.Errorf("%q: %v", part, err)
+			}
+			if target.waitMinutes, err = parsePositiveFloat(fields[2], 24*60); err != nil {
+				return target, fmt.Errorf("%q: %v", part, err)
+			}
+		case strings.EqualFold(fields[0], "abandon") && len(fields) == 2:
+			if target.maxAbandonment, err = parseFloat(fields[1], 0, 100); err != nil {
+				return target, fmt.Errorf("%q: %v", part, err)
+			}
+		default:
+			return target, fmt.Errorf("%q is not wait:percent:minutes or abandon:percent, for example wait:90:5", part)
+		}
+	}
+	return target, nil
+}
+
+func (t serviceTarget) String() string {
+	var parts []string
+	if t.waitShare >= 0 {
+		parts = append(parts, fmt.Sprintf("%g%% of customers wait under %g min", t.waitShare, t.waitMinutes))
+	}
+	if t.maxAbandonment >= 0 {
+		parts = append(parts, fmt.Sprintf("at most %g%% leave without paying", t.maxAbandonment))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// serviceLevel is how a store did over every run of a rota. Customers that gave up or
+// were turned away count as having waited too long.
+type serviceLevel struct {
+	reached   int
+	waitedOK  int
+	customers int
+	abandoned int
+}
+
+func (l serviceLevel) waitedOKPercent() float64 {
+	if l.reached == 0 {
+		return 100
+	}
+	return 100 * float64(l.waitedOK) / float64(l.reached)
+}
+
+func (l serviceLevel) abandonedPercent() float64 {
+	if l.customers == 0 {
+		return 0
+	}
+	return 100 * float64(l.abandoned) / float64(l.customers)
+}
+
+// betterThan compares two service levels on the wait first, then on who left.
+func (l serviceLevel) betterThan(other serviceLevel) bool {
+	if l.waitedOKPercent() != other.waitedOKPercent() {
+		return l.waitedOKPercent() > other.waitedOKPercent()
+	}
+	return l.abandonedPercent() < other.abandonedPercent()
+}
+
+func (t serviceTarget) metBy(l serviceLevel) bool {
+	return (t.waitShare < 0 || l.waitedOKPercent() >= t.waitShare) &&
+		(t.maxAbandonment < 0 || l.abandonedPercent() <= t.maxAbandonment)
+}
+
+// storeRota is the number of tills open in every hour of the day, tills[0] is the hour
+// the store opens. The store keeps its checkouts, lanes and cashiers: in every hour the
+// rota opens the first tills[hour] checkouts, in checkout order, of those the scenario has
+// somebody on, and they work the part of their shifts that falls in that hour.
+type storeRota struct {
+	opens     int
+	tills     []int
+	checkouts []*checkout
+}
+
+// newStoreRota is the scenario's own rota, every rostered till open when it has somebody
+// on. Kiosks and the floor manager's standby tills are not on the rota.
+func newStoreRota(eStore *store) storeRota {
+	rota := storeRota{opens: eStore.openingHoursFrom, tills: make([]int, eStore.openingHoursTo-eStore.openingHoursFrom)}
+	for _, kCheckout := range sortedCheckoutKeys(eStore) {
+		if eCheckout := eStore.checkouts[kCheckout]; !eCheckout.selfService && !eCheckout.standby {
+			rota.checkouts = append(rota.checkouts, eCheckout)
+		}
+	}
+	for hour := range rota.tills {
+		for _, eCheckout := range rota.checkouts {
+			if rota.staffedIn(eCheckout.roster, hour) {
+				rota.tills[hour]++
+			}
+		}
+	}
+	return rota
+}
+
+// staffedIn tells if any of the shifts covers part of the hour.
+func (r storeRota) staffedIn(shifts []shift, hour int) bool {
+	from, to := float64((r.opens+hour)*3600), float64((r.opens+hour+1)*3600)
+	for _, s := range shifts {
+		if s.from < to && s.to > from {
+			return true
+		}
+	}
+	return false
+}
+
+// onRota tells if the i-th checkout is one of the tills open in the hour.
+func (r storeRota) onRota(i int, hour int) bool {
+	if !r.staffedIn(r.checkouts[i].roster, hour) {
+		return false
+	}
+	before := 0
+	for _, eCheckout := range r.checkouts[:i] {
+		if r.staffedIn(eCheckout.roster, hour) {
+			before++
+		}
+	}
+	return before < r.tills[hour]
+}
+
+func (r storeRota) tillHours() int {
+	total := 0
+	for _, open := range r.tills {
+		total += open
+	}
+	return total
+}
+
+// labour is what the till-hours cost at the wage of every till.
+func (r storeRota) labour() float64 {
+	total := 0.0
+	for i, eCheckout := range r.checkouts {
+		for hour := range r.tills {
+			if r.onRota(i, hour) {
+				total += eCheckout.hourlyWage
+			}
+		}
+	}
+	return total
+}
+
+// roster is the i-th checkout's roster cut down to the hours it is on the rota. Every
+// cashier keeps their efficiency and the breaks that still fall within what is left of
+// their shift.
+func (r storeRota) roster(i int) string {
+	eCheckout := r.checkouts[i]
+	var shifts []string
+	for _, s := range eCheckout.roster {
+		for hour := 0; hour < len(r.tills); hour++ {
+			if !r.onRota(i, hour) || !r.staffedIn([]shift{s}, hour) {
+				continue
+			}
+			end := hour
+			for end < len(r.tills) && r.onRota(i, end) && r.staffedIn([]shift{s}, end) {
+				end++
+			}
+			piece := shift{cashier: s.cashier, efficiency: s.efficiency,
+				from: math.Max(s.from, float64((r.opens+hour)*3600)),
+				to:   math.Min(s.to, float64((r.opens+end)*3600))}
+			if piece.cashier == "" {
+				// The cashier of an ALL-DAY till has no name of their own.
+				piece.cashier = "TILL" + strconv.Itoa(eCheckout.checkoutId)
+			}
+			for _, onBreak := range s.breaks {
+				if onBreak.from >= piece.from && onBreak.to < piece.to {
+					piece.breaks = append(piece.breaks, onBreak)
+				}
+			}
+			shifts = append(shifts, formatShift(piece, eCheckout.cashierEfficiency))
+			hour = end
+		}
+	}
+	if len(shifts) == 0 {
+		return rosterClosed
+	}
+	return strings.Join(shifts, ",")
+}
+
+// rotaChanges are the answers that make a day use the rotas. The floor manager's reserve
+// staff would open tills of their own, so there is none.
+func rotaChanges(rotas map[string]storeRota) map[string]string {
+	changes := map[string]string{"reserveStaff": "0", "simulationMode": "E"}
+	for kStore, rota := range rotas {
+		for i, eCheckout := range rota.checkouts {
+			changes["["+kStore+"][checkout"+strconv.Itoa(eCheckout.checkoutId)+"]roster"] = rota.roster(i)
+		}
+	}
+	return changes
+}
+
+// evaluateRotas runs the day once for every seed with the rotas and adds up how every
+// store did.
+func evaluateRotas(ctx context.Context, seeds []int64, rotas map[string]storeRota, target serviceTarget) (map[string]serviceLevel, error) {
+	levels := map[string]serviceLevel{}
+	for _, seed := range seeds {
+		sim, err := replaySimulation(seed, rotaChanges(rotas))
+		if err != nil {
+			return nil, err
+		}
+		if err := sim.run(ctx); err != nil {
+			return nil, err
+		}
+		for kStore, eStore := range sim.stores {
+			level := levels[kStore]
+			for _, eCustomer := range eStore.customers {
+				level.customers++
+				if eCustomer.outcome != "" {
+					level.reached++
+				}
+				switch eCustomer.outcome {
+				case outcomeServed:
+					if float64(eCustomer.queueTimeSeconds) < target.waitMinutes*60 {
+						level.waitedOK++
+					}
+				case outcomeReneged, outcomeBalked, outcomeTurnedAway, outcomePaymentFailed:
+					level.abandoned++
+				}
+			}
+			levels[kStore] = level
+		}
+	}
+	return levels, nil
+}
+
+// optimiseRota takes tills away from one store's rota, one at a time, for as long as the
+// target is still met. Every step tries each hour and takes the till away from the hour
+// where the store does best without it. Fewer tills in an hour can leave longer lines for
+// the next one, which is why every try runs the whole day. An hour that could not lose a
+// till is not tried again.
+func optimiseRota(ctx context.Context, seeds []int64, rotas map[string]storeRota, kStore string,
+	target serviceTarget) (serviceLevel, error) {
+	levels, err := evaluateRotas(ctx, seeds, rotas, target)
+	if err != nil {
+		return serviceLevel{}, err
+	}
+	best := levels[kStore]
+	if !target.metBy(best) {
+		return best, nil
+	}
+
+	rota := rotas[kStore]
+	settled := make([]bool, len(rota.tills))
+	for {
+		bestHour := -1
+		var bestLevel serviceLevel
+		for hour := range rota.tills {
+			if settled[hour] || rota.tills[hour] <= 1 {
+				continue
+			}
+			rota.tills[hour]--
+			levels, err := evaluateRotas(ctx, seeds, rotas, target)
+			rota.tills[hour]++
+			if err != nil {
+				return best, err
+			}
+			if !target.metBy(levels[kStore]) {
+				settled[hour] = true
+				continue
+			}
+			if bestHour < 0 || levels[kStore].betterThan(bestLevel) {
+				bestHour, bestLevel = hour, levels[kStore]
+			}
+		}
+		if bestHour < 0 {
+			return best, nil
+		}
+		rota.tills[bestHour]--
+		best = bestLevel
+	}
+}
+
+func optimiseCommand(args []string) int {
+	fs := flag.NewFlagSet("optimise", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed of the first run, the others follow it (0 picks one from the clock)")
+	runs := fs.Int("runs", 3, "days to run for every rota tried, the target has to be met over all of them")
+	targetText := fs.String("target", "wait:90:5", "wait:percent:minutes, abandon:percent or both, for example wait:90:5,abandon:2")
+	scenario := addScenarioFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	interactive = false
+	if err := scenario.apply(fs); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not load config: "+err.Error())
+		return exitConfigError
+	}
+	target, err := parseServiceTarget(*targetText)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid --target: "+err.Error())
+		return exitConfigError
+	}
+	if *runs < 1 {
+		fmt.Fprintln(os.Stderr, "--runs has to be at least 1")
+		return exitConfigError
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	seeds := make([]int64, *runs)
+	for i := range seeds {
+		seeds[i] = *seed + int64(i)
+	}
+	fmt.Printf("Seed: %d (run again with --seed %d to get the same days)\n", *seed, *seed)
+
+	// Build the day once to learn the stores, their hours and their tills.
+	console = io.Discard
+	sim, err := setupSimulation(*seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Configuration error: "+err.Error())
+		return exitConfigError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	rotas := map[string]storeRota{}
+	for _, kStore := range sortedStoreKeys(sim.stores) {
+		rotas[kStore] = newStoreRota(sim.stores[kStore])
+	}
+
+	fmt.Printf("Target: %s, on %d days from seed %d\n", target, *runs, *seed)
+	for _, kStore := range sortedStoreKeys(sim.stores) {
+		eStore := sim.stores[kStore]
+		rota := rotas[kStore]
+		fullTillHours, fullLabour := rota.tillHours(), rota.labour()
+
+		level, err := optimiseRota(ctx, seeds, rotas, kStore, target)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Simulation failed: "+err.Error())
+			return exitSimulationError
+		}
+		printRota(os.Stdout, kStore, eStore, rota, fullTillHours, fullLabour, level, target)
+	}
+	return exitOK
+}
+
+// printRota is the hourly rota for one store, what it costs and the settings to run it.
+func printRota(out io.Writer, kStore string, eStore *store, rota storeRota, fullTillHours int, fullLabour float64,
+	level serviceLevel, target serviceTarget) {
+	fmt.Fprintf(out, "\n%s\n", kStore)
+	if !target.metBy(level) {
+		fmt.Fprintf(out, "The target is out of reach with the scenario's rota: %.1f%% waited under %g min, %.1f%% left.\n",
+			level.waitedOKPercent(), target.waitMinutes, level.abandonedPercent())
+		return
+	}
+
+	fmt.Fprintf(out, "%-6s %-5s %5s\n", "Hour", "Busy", "Tills")
+	for hour, open := range rota.tills {
+		code := "[" + kStore + "]busyRange_" + strconv.Itoa(rota.opens+hour)
+		fmt.Fprintf(out, "%02d:00  %-5s %5d\n", rota.opens+hour, answersGiven[code], open)
+	}
+	fmt.Fprintf(out, "Till-hours: %d, labour %.2f (the scenario's rota: %d, %.2f)\n",
+		rota.tillHours(), rota.labour(), fullTillHours, fullLabour)
+	fmt.Fprintf(out, "Waited under %g min: %.1f%%, left without paying: %.1f%%\n",
+		target.waitMinutes, level.waitedOKPercent(), level.abandonedPercent())
+
+	fmt.Fprintln(out, "To run it:")
+	for i, eCheckout := range rota.checkouts {
+		fmt.Fprintf(out, "  --set \"[%s][checkout%d]roster=%s\"\n", kStore, eCheckout.checkoutId, rota.roster(i))
+	}
+	if eStore.hasFloorManager && eStore.floorManager.reserveStaff > 0 {
+		fmt.Fprintf(out, "  --set \"[%s]reserveStaff=0\"\n", kStore)
+	}
+}
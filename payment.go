@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The ways a customer can pay. Split tender is a voucher with the rest on a card.
+const (
+	paymentContactless  = "contactless"
+	paymentChipAndPin   = "chip-and-pin"
+	paymentCash         = "cash"
+	paymentMobileWallet = "mobile-wallet"
+	paymentVoucher      = "voucher"
+	paymentSplit        = "split"
+)
+
+// paymentMethods in the order they are asked for and reported.
+var paymentMethods = []string{
+	paymentContactless, paymentChipAndPin, paymentCash, paymentMobileWallet, paymentVoucher, paymentSplit,
+}
+
+// defaultPaymentTimes are the seconds each method takes when nothing goes wrong.
+var defaultPaymentTimes = map[string]string{
+	paymentContactless:  "4-10",
+	paymentChipAndPin:   "12-25",
+	paymentCash:         "15-35",
+	paymentMobileWallet: "5-12",
+	paymentVoucher:      "15-30",
+}
+
+// maxPaymentAttempts is how many times a card is tried before the customer pays cash.
+const maxPaymentAttempts = 3
+
+type paymentShare struct {
+	method string
+	weight int
+}
+
+type paymentSettings struct {
+	mix           []paymentShare
+	times         map[string]floatRange
+	changeTime    floatRange
+	declineChance float64
+}
+
+// parsePaymentMix reads "contactless=50,cash=15,..." where the numbers are relative
+// weights, methods left out are never used.
+func parsePaymentMix(text string) ([]paymentShare, error) {
+	var mix []paymentShare
+	total := 0
+	for _, part := range strings.Split(text, ",") {
+		pieces := strings.Split(strings.TrimSpace(part), "=")
+		if len(pieces) != 2 {
+			return nil, fmt.Errorf("%q is not method=weight, for example contactless=50", part)
+		}
+		method := strings.ToLower(strings.TrimSpace(pieces[0]))
+		if _, err := parseChoice(method, paymentMethods...); err != nil {
+			return nil, err
+		}
+		weight, err := parseInt(pieces[1], 0, 1000000)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", method, err)
+		}
+		mix = append(mix, paymentShare{method: method, weight: weight})
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%q: at least one method needs a weight above 0", text)
+	}
+	return mix, nil
+}
+
+func readPaymentSettings(iStore int, defaultSettingsCode string) (paymentSettings, error) {
+	settings := paymentSettings{times: map[string]floatRange{}}
+	prefix := "[Store " + strconv.Itoa(iStore) + "] "
+	code := "[store" + strconv.Itoa(iStore) + "]"
+
+	err := readValidFromConsole(
+		prefix+"How do customers pay? Relative weights per method, for example "+
+			"[contactless=50,chip-and-pin=15,cash=15,mobile-wallet=12,voucher=3,split=5]",
+		"contactless=50,chip-and-pin=15,cash=15,mobile-wallet=12,voucher=3,split=5",
+		defaultSettingsCode,
+		code+"paymentMix",
+		func(text string) (err error) {
+			settings.mix, err = parsePaymentMix(text)
+			return err
+		})
+	if err != nil {
+		return settings, err
+	}
+
+	for _, method := range paymentMethods {
+		if method == paymentSplit {
+			// A voucher and then a card, it takes as long as both.
+			continue
+		}
+		settings.times[method], err = readFloatRange(
+			prefix+"How many seconds does paying by "+method+" take? ["+defaultPaymentTimes[method]+"] ",
+			defaultPaymentTimes[method],
+			defaultSettingsCode,
+			code+"paymentTime_"+method,
+			0, 3600)
+		if err != nil {
+			return settings, err
+		}
+	}
+
+	settings.changeTime, err = readFloatRange(
+		prefix+"How many seconds does counting the change take for cash? [5-15] ",
+		"5-15",
+		defaultSettingsCode,
+		code+"changeTime",
+		0, 3600)
+	if err != nil {
+		return settings, err
+	}
+	settings.declineChance, err = readFloat(
+		prefix+"How likely is a card to be declined? From 0 to 1 [0.02] ",
+		"0.02",
+		defaultSettingsCode,
+		code+"declineChance",
+		0, 1)
+	return settings, err
+}
+
+// pickPaymentMethod draws a customer's method from the store's mix.
+func (ps paymentSettings) pickPaymentMethod(stream *rand.Rand) string {
+	total := 0
+	for _, share := range ps.mix {
+		total += share.weight
+	}
+	pick := generateRandomNumber(stream, 1, total)
+	for _, share := range ps.mix {
+		pick -= share.weight
+		if pick <= 0 {
+			return share.method
+		}
+	}
+	return ps.mix[len(ps.mix)-1].method
+}
+
+// expectedSeconds is the average time a customer takes to pay, ignoring declines.
+func (ps paymentSettings) expectedSeconds() float64 {
+	mean := func(method string) float64 {
+		return (ps.times[method].from + ps.times[method].to) / 2
+	}
+	total, weights := 0.0, 0
+	for _, share := range ps.mix {
+		seconds := 0.0
+		switch share.method {
+		case paymentSplit:
+			seconds = mean(paymentVoucher) + mean(paymentChipAndPin)
+		case paymentCash:
+			seconds = mean(paymentCash) + (ps.changeTime.from+ps.changeTime.to)/2
+		default:
+			seconds = mean(share.method)
+		}
+		total += seconds * float64(share.weight)
+		weights += share.weight
+	}
+	return total / float64(weights)
+}
+
+func drawSeconds(stream *rand.Rand, r floatRange) float64 {
+	return r.from + stream.Float64()*(r.to-r.from)
+}
+
+// pay is the customer paying at the checkout, it returns false if they could not. Cards can
+// be declined, the customer then tries chip and PIN and after maxPaymentAttempts pays cash,
+// or at a lane that takes no cash leaves the shopping behind.
+func pay(store *store, checkout *checkout, customer *customer) bool {
+	sim := store.sim
+	settings := store.payments
+	stream := customer.rng.payments
+	started := sim.engine.now
+
+	sim.logf("Customer %4d is paying by %s at Checkout %2d...\n",
+		customer.customerId, customer.paymentMethod, checkout.checkoutId)
+
+	method := customer.paymentMethod
+	if method == paymentSplit {
+		sim.sleep(drawSeconds(stream, settings.times[paymentVoucher]))
+		method = paymentChipAndPin
+	}
+
+	for attempt := 1; ; attempt++ {
+		if method == paymentCash || method == paymentVoucher {
+			sim.sleep(drawSeconds(stream, settings.times[method]))
+			if method == paymentCash {
+				sim.sleep(drawSeconds(stream, settings.changeTime))
+			}
+			break
+		}
+
+		sim.sleep(drawSeconds(stream, settings.times[method]))
+		if stream.Float64() >= settings.declineChance {
+			break
+		}
+		customer.paymentDeclines++
+		sim.logf("Customer %4d: card declined at Checkout %2d\n", customer.customerId, checkout.checkoutId)
+		if attempt >= maxPaymentAttempts {
+			if !checkout.lane.takesPayment(paymentCash) {
+				sim.logf("Customer %4d can not pay at Checkout %2d and leaves the shopping\n",
+					customer.customerId, checkout.checkoutId)
+				customer.paymentSeconds = sim.engine.now - started
+				return false
+			}
+			method = paymentCash
+		} else {
+			method = paymentChipAndPin
+		}
+	}
+
+	customer.paymentSeconds = sim.engine.now - started
+	return true
+}
+
+// printPaymentReport breaks the payment time down by method.
+func printPaymentReport(out io.Writer, eStore *store) {
+	times := map[string][]float64{}
+	declines := map[string]int{}
+	served, failed := 0, 0
+	for _, eCustomer := range sortedCustomers(eStore) {
+		if eCustomer.outcome == outcomePaymentFailed {
+			failed++
+		}
+		if eCustomer.outcome != outcomeServed {
+			continue
+		}
+		served++
+		times[eCustomer.paymentMethod] = append(times[eCustomer.paymentMethod], eCustomer.paymentSeconds)
+		declines[eCustomer.paymentMethod] += eCustomer.paymentDeclines
+	}
+	if served == 0 {
+		return
+	}
+
+	methods := append([]string(nil), paymentMethods...)
+	sort.SliceStable(methods, func(i, j int) bool { return len(times[methods[i]]) > len(times[methods[j]]) })
+
+	fmt.Fprintf(out, "%-14s %9s %6s %8s %6s %8s\n", "Payment", "Customers", "Share%", "Mean(s)", "p90(s)", "Declines")
+	for _, method := range methods {
+		if len(times[method]) == 0 {
+			continue
+		}
+		d := summarise(times[method])
+		fmt.Fprintf(out, "%-14s %9d %6.1f %8.1f %6.1f %8d\n",
+			method, d.count, 100*float64(d.count)/float64(served), d.mean, d.p90, declines[method])
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "Left the shopping after %d declines at a lane that takes no cash: %d\n", maxPaymentAttempts, failed)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPaymentMix(t *testing.T) {
+	eStore := runTestDay(t, 5, map[string]string{
+		"openingHours":  "9-12",
+		"paymentMix":    "cash=1",
+		"declineChance": "0.9",
+	}).stores["store1"]
+
+	for _, eCustomer := range sortedCustomers(eStore) {
+		if eCustomer.paymentMethod != paymentCash {
+			t.Errorf("customer %d pays by %s, the store only has cash payers", eCustomer.customerId, eCustomer.paymentMethod)
+		}
+		if eCustomer.paymentDeclines != 0 {
+			t.Errorf("customer %d had cash declined %d times", eCustomer.customerId, eCustomer.paymentDeclines)
+		}
+	}
+}
+
+func TestDeclinedCardsAtACardOnlyLane(t *testing.T) {
+	// Most cards are declined three times, checkout1 takes no cash to pay with instead.
+	eStore := runTestDay(t, 5, map[string]string{
+		"openingHours":                   "9-12",
+		"numberOfCheckouts":              "2",
+		"maxQueueTime":                   "1440-1440",
+		"[store1][checkout1]lanePayment": lanePaymentCard,
+		"paymentMix":                     "contactless=1",
+		"declineChance":                  "0.9",
+	}).stores["store1"]
+
+	paidCash, failed := 0, 0
+	for _, eCustomer := range sortedCustomers(eStore) {
+		if eCustomer.checkoutTimeStart == 0 {
+			continue
+		}
+		declinedEveryCard := eCustomer.paymentDeclines == maxPaymentAttempts
+		switch {
+		case declinedEveryCard && eCustomer.checkoutId == 1:
+			failed++
+			if eCustomer.outcome != outcomePaymentFailed || eCustomer.purchaseComplete {
+				t.Errorf("customer %d paid at the card-only lane after %d declines", eCustomer.customerId, maxPaymentAttempts)
+			}
+		case declinedEveryCard:
+			paidCash++
+			if eCustomer.outcome != outcomeServed {
+				t.Errorf("customer %d did not pay cash after %d declines, %q", eCustomer.customerId, maxPaymentAttempts, eCustomer.outcome)
+			}
+		case eCustomer.outcome != outcomeServed:
+			t.Errorf("customer %d had %d declines and ended the day %q", eCustomer.customerId, eCustomer.paymentDeclines, eCustomer.outcome)
+		}
+	}
+	if paidCash == 0 || failed == 0 {
+		t.Fatalf("%d paid cash and %d could not pay, want both", paidCash, failed)
+	}
+
+	var report bytes.Buffer
+	printPaymentReport(&report, eStore)
+	want := fmt.Sprintf("Left the shopping after %d declines at a lane that takes no cash: %d\n", maxPaymentAttempts, failed)
+	if !strings.Contains(report.String(), want) {
+		t.Errorf("payment report does not say %q:\n%s", want, report.String())
+	}
+}
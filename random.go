@@ -0,0 +1,77 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+)
+
+// randomStreams gives each kind of random draw its own generator. Every stream is
+// seeded from the run seed plus its own name, so for example adding a checkout only
+// changes the routing draws and every customer still gets the same basket and patience.
+type randomStreams struct {
+	seed   int64
+	prefix string
+
+	arrivals  *rand.Rand
+	baskets   *rand.Rand
+	scanTimes *rand.Rand
+	patience  *rand.Rand
+	routing   *rand.Rand
+	jockeying *rand.Rand
+	shopping  *rand.Rand
+
+	selfCheckout *rand.Rand
+	payments     *rand.Rand
+	lanes        *rand.Rand
+	catalogue    *rand.Rand
+	prices       *rand.Rand
+}
+
+func newRandomStreams(seed int64, storeId int) *randomStreams {
+	prefix := "store" + strconv.Itoa(storeId) + "/"
+	return &randomStreams{
+		seed:   seed,
+		prefix: prefix,
+
+		arrivals:  newRandomStream(seed, prefix+"arrivals"),
+		baskets:   newRandomStream(seed, prefix+"baskets"),
+		scanTimes: newRandomStream(seed, prefix+"scanTimes"),
+		patience:  newRandomStream(seed, prefix+"patience"),
+		routing:   newRandomStream(seed, prefix+"routing"),
+		jockeying: newRandomStream(seed, prefix+"jockeying"),
+		shopping:  newRandomStream(seed, prefix+"shopping"),
+
+		selfCheckout: newRandomStream(seed, prefix+"selfCheckout"),
+		payments:     newRandomStream(seed, prefix+"payments"),
+		lanes:        newRandomStream(seed, prefix+"lanes"),
+		catalogue:    newRandomStream(seed, prefix+"catalogue"),
+		prices:       newRandomStream(seed, prefix+"prices"),
+	}
+}
+
+// customerStreams are the draws made while a customer is at the checkout: how long paying
+// and any exceptions take and whether the card goes through, and what happens at a kiosk.
+// Every customer has their own, so serving them at another till or in another order does
+// not change their draws, nor anybody else's.
+type customerStreams struct {
+	payments     *rand.Rand
+	exceptions   *rand.Rand
+	selfCheckout *rand.Rand
+}
+
+func (r *randomStreams) forCustomer(customerId int) *customerStreams {
+	prefix := r.prefix + "customer" + strconv.Itoa(customerId) + "/"
+	return &customerStreams{
+		payments:     newRandomStream(r.seed, prefix+"payments"),
+		exceptions:   newRandomStream(r.seed, prefix+"exceptions"),
+		selfCheckout: newRandomStream(r.seed, prefix+"selfCheckout"),
+	}
+}
+
+// newRandomStream mixes the stream name into the seed, so two streams never share a sequence.
+func newRandomStream(seed int64, name string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatInt(seed, 10) + "/" + name))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// intRange is a "from-to" answer such as 350-450 customers or 9-22 opening hours.
+type intRange struct {
+	from int
+	to   int
+}
+
+// floatRange is a "from-to" answer with decimals such as 0.5-6 seconds.
+type floatRange struct {
+	from float64
+	to   float64
+}
+
+// splitRange splits "from-to" into its two halves. A single number is read as from == to.
+func splitRange(text string) (string, string, error) {
+	text = strings.TrimSpace(text)
+	if strings.ContainsAny(text, "‐‑‒–—−") {
+		return "", "", fmt.Errorf("%q uses a dash that is not \"-\", type the range as from-to, for example 350-450", text)
+	}
+
+	parts := strings.Split(text, "-")
+	switch len(parts) {
+	case 1:
+		if _, err := strconv.ParseFloat(parts[0], 64); err == nil {
+			return parts[0], parts[0], nil
+		}
+	case 2:
+		return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+	}
+	return "", "", fmt.Errorf("%q is not a range, type it as from-to, for example 350-450", text)
+}
+
+func parseIntRange(text string, min int, max int) (intRange, error) {
+	fromText, toText, err := splitRange(text)
+	if err != nil {
+		return intRange{}, err
+	}
+	from, err := parseInt(fromText, min, max)
+	if err != nil {
+		return intRange{}, fmt.Errorf("range %q: %v", text, err)
+	}
+	to, err := parseInt(toText, min, max)
+	if err != nil {
+		return intRange{}, fmt.Errorf("range %q: %v", text, err)
+	}
+	if from > to {
+		return intRange{}, fmt.Errorf("range %q: from (%d) is greater than to (%d)", text, from, to)
+	}
+	return intRange{from: from, to: to}, nil
+}
+
+func parseFloatRange(text string, min float64, max float64) (floatRange, error) {
+	fromText, toText, err := splitRange(text)
+	if err != nil {
+		return floatRange{}, err
+	}
+	from, err := parseFloat(fromText, min, max)
+	if err != nil {
+		return floatRange{}, fmt.Errorf("range %q: %v", text, err)
+	}
+	to, err := parseFloat(toText, min, max)
+	if err != nil {
+		return floatRange{}, fmt.Errorf("range %q: %v", text, err)
+	}
+	if from > to {
+		return floatRange{}, fmt.Errorf("range %q: from (%g) is greater than to (%g)", text, from, to)
+	}
+	return floatRange{from: from, to: to}, nil
+}
+
+// parseOpeningHours is a range of hours of the day where the store has to be open for at least an hour.
+func parseOpeningHours(text string) (intRange, error) {
+	hours, err := parseIntRange(text, 0, 24)
+	if err != nil {
+		return intRange{}, err
+	}
+	if hours.from == hours.to {
+		return intRange{}, fmt.Errorf("opening hours %q: the store has to close after it opens", text)
+	}
+	return hours, nil
+}
+
+func parseInt(text string, min int, max int) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a whole number", text)
+	}
+	if value < min || value > max {
+		return 0, fmt.Errorf("%d is not between %d and %d", value, min, max)
+	}
+	return value, nil
+}
+
+func parseFloat(text string, min float64, max float64) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", text)
+	}
+	// NaN passes every comparison below, and nothing we ask for is infinite.
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%q is not a number", text)
+	}
+	if value < min || value > max {
+		return 0, fmt.Errorf("%g is not between %g and %g", value, min, max)
+	}
+	return value, nil
+}
+
+// parsePositiveFloat is for values like cashierEfficiency where 0 makes no sense.
+func parsePositiveFloat(text string, max float64) (float64, error) {
+	value, err := parseFloat(text, 0, max)
+	if err != nil {
+		return 0, err
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("%g has to be greater than 0", value)
+	}
+	return value, nil
+}
+
+// parseChoice checks the answer is one of the listed options.
+func parseChoice(text string, choices ...string) (string, error) {
+	for _, choice := range choices {
+		if text == choice {
+			return text, nil
+		}
+	}
+	return "", fmt.Errorf("%q is not one of %s", text, strings.Join(choices, ", "))
+}
+
+// upperAll is for choices, the answers are always converted to upper case.
+func upperAll(choices []string) []string {
+	upper := make([]string, len(choices))
+	for i, choice := range choices {
+		upper[i] = strings.ToUpper(choice)
+	}
+	return upper
+}
+
+// readValidFromConsole is readFromConsole plus a check of the answer. When the answer
+// was typed in the console the question is asked again, otherwise (scenario, file or
+// flag) the error is returned so the run stops with a configuration error.
+func readValidFromConsole(label string, defaultValue string, defaultSettingsCode string, code string,
+	validate func(string) error) error {
+
+	return readValidTextFromConsole(label, true, defaultValue, defaultSettingsCode, code, validate)
+}
+
+// readValidTextFromConsole is readValidFromConsole for answers such as file names, where
+// convertToUpper is false to keep them as typed.
+func readValidTextFromConsole(label string, convertToUpper bool, defaultValue string, defaultSettingsCode string,
+	code string, validate func(string) error) error {
+
+	for {
+		text := readFromConsole(label, convertToUpper, defaultValue, defaultSettingsCode, code)
+		err := validate(text)
+		if err == nil {
+			return nil
+		}
+		if !interactive || !isAskedInConsole(defaultSettingsCode, code) {
+			return fmt.Errorf("%s: %v", code, err)
+		}
+		fmt.Fprintln(console, "Invalid answer: "+err.Error()+". Please try again.")
+	}
+}
+
+func readIntRange(label string, defaultValue string, defaultSettingsCode string, code string,
+	min int, max int) (intRange, error) {
+
+	var value intRange
+	err := readValidFromConsole(label, defaultValue, defaultSettingsCode, code, func(text string) (err error) {
+		value, err = parseIntRange(text, min, max)
+		return err
+	})
+	return value, err
+}
+
+func readFloatRange(label string, defaultValue string, defaultSettingsCode string, code string,
+	min float64, max float64) (floatRange, error) {
+
+	var value floatRange
+	err := readValidFromConsole(label, defaultValue, defaultSettingsCode, code, func(text string) (err error) {
+		value, err = parseFloatRange(text, min, max)
+		return err
+	})
+	return value, err
+}
+
+func readOpeningHours(label string, defaultValue string, defaultSettingsCode string, code string) (intRange, error) {
+	var value intRange
+	err := readValidFromConsole(label, defaultValue, defaultSettingsCode, code, func(text string) (err error) {
+		value, err = parseOpeningHours(text)
+		return err
+	})
+	return value, err
+}
+
+func readInt(label string, defaultValue string, defaultSettingsCode string, code string,
+	min int, max int) (int, error) {
+
+	var value int
+	err := readValidFromConsole(label, defaultValue, defaultSettingsCode, code, func(text string) (err error) {
+		value, err = parseInt(text, min, max)
+		return err
+	})
+	return value, err
+}
+
+func readFloat(label string, defaultValue string, defaultSettingsCode string, code string,
+	min float64, max float64) (float64, error) {
+
+	var value float64
+	err := readValidFromConsole(label, defaultValue, defaultSettingsCode, code, func(text string) (err error) {
+		value, err = parseFloat(text, min, max)
+		return err
+	})
+	return value, err
+}
+
+func readPositiveFloat(label string, defaultValue string, defaultSettingsCode string, code string,
+	max float64) (float64, error) {
+
+	var value float64
+	err := readValidFromConsole(label, defaultValue, defaultSettingsCode, code, func(text string) (err error) {
+		value, err = parsePositiveFloat(text, max)
+		return err
+	})
+	return value, err
+}
+
+func readChoice(label string, defaultValue string, defaultSettingsCode string, code string,
+	choices ...string) (string, error) {
+
+	var value string
+	err := readValidFromConsole(label, defaultValue, defaultSettingsCode, code, func(text string) (err error) {
+		value, err = parseChoice(text, choices...)
+		return err
+	})
+	return value, err
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIntRange(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    intRange
+		wantErr string
+	}{
+		{"350-450", intRange{350, 450}, ""},
+		{" 9 - 22 ", intRange{9, 22}, ""},
+		{"5", intRange{5, 5}, ""},
+		{"0-0", intRange{0, 0}, ""},
+		{"350–450", intRange{}, "dash that is not"},
+		{"350—450", intRange{}, "dash that is not"},
+		{"", intRange{}, "is not a range"},
+		{"abc", intRange{}, "is not a range"},
+		{"1-2-3", intRange{}, "is not a range"},
+		{"1.5-3", intRange{}, "is not a whole number"},
+		{"1-", intRange{}, "is not a whole number"},
+		{"450-350", intRange{}, "from (450) is greater than to (350)"},
+		{"0-1001", intRange{}, "1001 is not between 0 and 1000"},
+	}
+	for _, test := range tests {
+		got, err := parseIntRange(test.text, 0, 1000)
+		checkParse(t, "parseIntRange", test.text, got, test.want, err, test.wantErr)
+	}
+}
+
+func TestParseFloatRange(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    floatRange
+		wantErr string
+	}{
+		{"0.5-6", floatRange{0.5, 6}, ""},
+		{"2", floatRange{2, 2}, ""},
+		{"1e1-20", floatRange{10, 20}, ""},
+		{"6-0.5", floatRange{}, "from (6) is greater than to (0.5)"},
+		{"0.5−6", floatRange{}, "dash that is not"},
+		{"NaN-6", floatRange{}, "\"NaN\" is not a number"},
+		{"1-Inf", floatRange{}, "\"Inf\" is not a number"},
+		{"1-101", floatRange{}, "101 is not between 0 and 100"},
+	}
+	for _, test := range tests {
+		got, err := parseFloatRange(test.text, 0, 100)
+		checkParse(t, "parseFloatRange", test.text, got, test.want, err, test.wantErr)
+	}
+}
+
+func TestParseOpeningHours(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    intRange
+		wantErr string
+	}{
+		{"9-22", intRange{9, 22}, ""},
+		{"0-24", intRange{0, 24}, ""},
+		{"9-9", intRange{}, "has to close after it opens"},
+		{"9", intRange{}, "has to close after it opens"},
+		{"22-9", intRange{}, "is greater than"},
+		{"9-25", intRange{}, "25 is not between 0 and 24"},
+	}
+	for _, test := range tests {
+		got, err := parseOpeningHours(test.text)
+		checkParse(t, "parseOpeningHours", test.text, got, test.want, err, test.wantErr)
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    int
+		wantErr string
+	}{
+		{"3", 3, ""},
+		{" 10 ", 10, ""},
+		{"0", 0, ""},
+		{"-1", 0, "-1 is not between 0 and 10"},
+		{"11", 0, "11 is not between 0 and 10"},
+		{"2.5", 0, "is not a whole number"},
+		{"", 0, "is not a whole number"},
+	}
+	for _, test := range tests {
+		got, err := parseInt(test.text, 0, 10)
+		checkParse(t, "parseInt", test.text, got, test.want, err, test.wantErr)
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    float64
+		wantErr string
+	}{
+		{"0.25", 0.25, ""},
+		{" 1 ", 1, ""},
+		{"0", 0, ""},
+		{"-0.1", 0, "-0.1 is not between 0 and 1"},
+		{"1.5", 0, "1.5 is not between 0 and 1"},
+		{"NaN", 0, "is not a number"},
+		{"nan", 0, "is not a number"},
+		{"Inf", 0, "is not a number"},
+		{"-Infinity", 0, "is not a number"},
+		{"half", 0, "is not a number"},
+	}
+	for _, test := range tests {
+		got, err := parseFloat(test.text, 0, 1)
+		checkParse(t, "parseFloat", test.text, got, test.want, err, test.wantErr)
+	}
+}
+
+func TestParsePositiveFloat(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    float64
+		wantErr string
+	}{
+		{"1.5", 1.5, ""},
+		{"10", 10, ""},
+		{"0", 0, "has to be greater than 0"},
+		{"-1", 0, "is not between 0 and 10"},
+		{"11", 0, "is not between 0 and 10"},
+		{"Inf", 0, "is not a number"},
+	}
+	for _, test := range tests {
+		got, err := parsePositiveFloat(test.text, 10)
+		checkParse(t, "parsePositiveFloat", test.text, got, test.want, err, test.wantErr)
+	}
+}
+
+func TestParseChoice(t *testing.T) {
+	if got, err := parseChoice("B", "A", "B"); err != nil || got != "B" {
+		t.Errorf("parseChoice(B) = %q, %v, want B", got, err)
+	}
+	if _, err := parseChoice("b", "A", "B"); err == nil || !strings.Contains(err.Error(), "is not one of A, B") {
+		t.Errorf("parseChoice(b) error = %v, want \"is not one of A, B\"", err)
+	}
+}
+
+// checkParse compares what a parser returned with the table, wantErr is a part of the
+// error message or empty when the text is valid.
+func checkParse(t *testing.T, parser string, text string, got interface{}, want interface{}, err error, wantErr string) {
+	t.Helper()
+	switch {
+	case wantErr == "" && err != nil:
+		t.Errorf("%s(%q) failed: %v", parser, text, err)
+	case wantErr == "" && got != want:
+		t.Errorf("%s(%q) = %v, want %v", parser, text, got, want)
+	case wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)):
+		t.Errorf("%s(%q) error = %v, want %q", parser, text, err, wantErr)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+)
+
+// answersGiven is every answer setupSimulation used, by prompt code, whether it was typed,
+// passed as a flag or came from a scenario. It lets us build the same day again.
+var answersGiven = map[string]string{}
+
+// replaySimulation builds the day setupSimulation last built again, with the same answers
+// and seed, quietly and without asking anything. changes replaces some of the answers, by
+// prompt code without the [storeN] and [checkoutN] part, e.g. "jockeying": "N" for every store,
+// or by the full code for a single store or checkout.
+func replaySimulation(seed int64, changes map[string]string) (*simulation, error) {
+	savedOverrides, savedAnswers := promptOverrides, answersGiven
+	savedInteractive, savedConsole := interactive, console
+	defer func() {
+		promptOverrides, answersGiven = savedOverrides, savedAnswers
+		interactive, console = savedInteractive, savedConsole
+	}()
+
+	promptOverrides = map[string]string{}
+	for code, text := range savedAnswers {
+		promptOverrides[code] = text
+		if change, ok := changes[code]; ok {
+			promptOverrides[code] = change
+		} else if change, ok := changes[promptScope.ReplaceAllString(code, "")]; ok {
+			promptOverrides[code] = change
+		}
+	}
+	for code, text := range changes {
+		if _, ok := promptOverrides[code]; !ok {
+			promptOverrides[code] = text
+		}
+	}
+	interactive = false
+	console = io.Discard
+
+	sim, err := setupSimulation(seed)
+	if err != nil {
+		return nil, err
+	}
+	sim.out = io.Discard
+	return sim, nil
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// rosterAllDay is the roster answer for one cashier looking after the till all day, how
+// it always was.
+const rosterAllDay = "ALL-DAY"
+
+// rosterClosed is the roster answer for a till nobody works today.
+const rosterClosed = "CLOSED"
+
+// shift is one cashier at a till, times are seconds since midnight. The till is closed
+// during the breaks.
+type shift struct {
+	cashier    string
+	efficiency float64
+	from       float64
+	to         float64
+	breaks     []floatRange
+}
+
+// parseClock reads a time of day as "14" or "14:30", in seconds since midnight.
+func parseClock(text string) (float64, error) {
+	hourText, minuteText := strings.TrimSpace(text), "0"
+	if i := strings.Index(hourText, ":"); i >= 0 {
+		hourText, minuteText = hourText[:i], hourText[i+1:]
+	}
+	hour, err := parseInt(hourText, 0, 24)
+	if err != nil {
+		return 0, fmt.Errorf("time %q: %v", text, err)
+	}
+	minute, err := parseInt(minuteText, 0, 59)
+	if err != nil {
+		return 0, fmt.Errorf("time %q: %v", text, err)
+	}
+	if hour == 24 && minute > 0 {
+		return 0, fmt.Errorf("time %q is after midnight", text)
+	}
+	return float64(hour*3600 + minute*60), nil
+}
+
+// formatClock writes seconds since midnight the way parseClock reads them.
+func formatClock(seconds float64) string {
+	hour, minute := int(seconds)/3600, int(seconds)%3600/60
+	if minute == 0 {
+		return strconv.Itoa(hour)
+	}
+	return fmt.Sprintf("%d:%02d", hour, minute)
+}
+
+// staysToClose makes the shifts that run up to closing time last until everybody in the
+// line has been served, as a cashier would.
+func staysToClose(roster []shift, closing float64) {
+	for i := range roster {
+		if roster[i].to >= closing {
+			roster[i].to = math.Inf(1)
+		}
+	}
+}
+
+// parseRoster reads the shifts of a till in the order they are worked, for example
+// "ANNA:1.2@9-14/12:00+15,BEN@14-22/18:00+30" is Anna with efficiency 1.2 from 9 to 14
+// with a 15 minute break at noon, then Ben at the till's own efficiency from 14 to 22
+// with half an hour at 18:00. ALL-DAY is one cashier that never leaves the till, CLOSED
+// nobody at all.
+func parseRoster(text string, efficiency float64) ([]shift, error) {
+	switch strings.TrimSpace(text) {
+	case rosterAllDay:
+		return []shift{{efficiency: efficiency, from: 0, to: math.Inf(1)}}, nil
+	case rosterClosed:
+		return nil, nil
+	}
+
+	var roster []shift
+	for _, part := range strings.Split(text, ",") {
+		pieces := strings.Split(strings.TrimSpace(part), "/")
+		who, hours, found := strings.Cut(pieces[0], "@")
+		if !found {
+			return nil, fmt.Errorf("%q is not name@from-to, for example ANNA@9-14", part)
+		}
+
+		s := shift{efficiency: efficiency}
+		s.cashier, _, _ = strings.Cut(who, ":")
+		s.cashier = strings.TrimSpace(s.cashier)
+		if s.cashier == "" {
+			return nil, fmt.Errorf("%q: the cashier needs a name", part)
+		}
+		if _, efficiencyText, ok := strings.Cut(who, ":"); ok {
+			var err error
+			if s.efficiency, err = parsePositiveFloat(efficiencyText, 10); err != nil {
+				return nil, fmt.Errorf("%s: %v", s.cashier, err)
+			}
+		}
+
+		fromText, toText, found := strings.Cut(hours, "-")
+		if !found {
+			return nil, fmt.Errorf("%s: %q is not a shift from-to, for example 9-14", s.cashier, hours)
+		}
+		var err error
+		if s.from, err = parseClock(fromText); err != nil {
+			return nil, fmt.Errorf("%s: %v", s.cashier, err)
+		}
+		if s.to, err = parseClock(toText); err != nil {
+			return nil, fmt.Errorf("%s: %v", s.cashier, err)
+		}
+		if s.from >= s.to {
+			return nil, fmt.Errorf("%s: the shift %q has to end after it starts", s.cashier, hours)
+		}
+		if len(roster) > 0 && s.from < roster[len(roster)-1].to {
+			return nil, fmt.Errorf("%s: the shift starts before %s's has finished", s.cashier, roster[len(roster)-1].cashier)
+		}
+
+		for _, breakText := range pieces[1:] {
+			startText, minutesText, found := strings.Cut(breakText, "+")
+			if !found {
+				return nil, fmt.Errorf("%s: %q is not a break time+minutes, for example 12:00+15", s.cashier, breakText)
+			}
+			start, err := parseClock(startText)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", s.cashier, err)
+			}
+			minutes, err := parseInt(minutesText, 1, 24*60)
+			if err != nil {
+				return nil, fmt.Errorf("%s: break %q: %v", s.cashier, breakText, err)
+			}
+			onBreak := floatRange{from: start, to: start + float64(minutes*60)}
+			if onBreak.from < s.from || onBreak.to >= s.to {
+				return nil, fmt.Errorf("%s: the break %q is not within the shift", s.cashier, breakText)
+			}
+			if len(s.breaks) > 0 && onBreak.from < s.breaks[len(s.breaks)-1].to {
+				return nil, fmt.Errorf("%s: the break %q starts before the one before it has finished", s.cashier, breakText)
+			}
+			s.breaks = append(s.breaks, onBreak)
+		}
+		roster = append(roster, s)
+	}
+	return roster, nil
+}
+
+// formatShift writes a shift the way parseRoster reads it, the efficiency only when it is
+// not the till's own. A shift that runs until closing has to be given its closing time.
+func formatShift(s shift, tillEfficiency float64) string {
+	text := s.cashier
+	if s.efficiency != tillEfficiency {
+		text += ":" + strconv.FormatFloat(s.efficiency, 'g', -1, 64)
+	}
+	text += "@" + formatClock(s.from) + "-" + formatClock(s.to)
+	for _, onBreak := range s.breaks {
+		text += "/" + formatClock(onBreak.from) + "+" + strconv.Itoa(int(onBreak.to-onBreak.from)/60)
+	}
+	return text
+}
+
+func readRoster(iStore int, iCheckout int, efficiency float64, closing int,
+	defaultSettingsCode string) ([]shift, error) {
+
+	var roster []shift
+	err := readValidFromConsole(
+		"[Store "+strconv.Itoa(iStore)+"][Checkout "+strconv.Itoa(iCheckout)+"] Who works this checkout and when? "+
+			"For example ANNA:1.2@9-14/12:00+15,BEN@14-22 is Anna (efficiency 1.2) until 14 with a 15 minute break "+
+			"at noon, then Ben, CLOSED is nobody. [ALL-DAY] is one cashier for the whole day ",
+		rosterAllDay,
+		defaultSettingsCode,
+		"[store"+strconv.Itoa(iStore)+"][checkout"+strconv.Itoa(iCheckout)+"]roster",
+		func(text string) (err error) {
+			roster, err = parseRoster(text, efficiency)
+			return err
+		})
+	staysToClose(roster, float64(closing*3600))
+	return roster, err
+}
+
+// checkRosterCoversClosing makes sure somebody is still on a till when the store closes,
+// otherwise the customers in the aisles would have nowhere to pay.
+func checkRosterCoversClosing(iStore int, checkouts map[string]*checkout) error {
+	for _, eCheckout := range checkouts {
+		if len(eCheckout.roster) > 0 && math.IsInf(eCheckout.roster[len(eCheckout.roster)-1].to, 1) {
+			return nil
+		}
+	}
+	return fmt.Errorf("[store%d]roster: nobody is on a till at closing time", iStore)
+}
+
+// onDuty is the shift working the till at the time given, nil while it is closed. until
+// is when the cashier next leaves the till, for a break or at the end of the shift.
+func (c *checkout) onDuty(at float64) (current *shift, until float64) {
+	for i := range c.roster {
+		s := &c.roster[i]
+		if at < s.from || at >= s.to {
+			continue
+		}
+		until = s.to
+		for _, onBreak := range s.breaks {
+			if at >= onBreak.from && at < onBreak.to {
+				return nil, 0
+			}
+			if onBreak.from > at {
+				until = math.Min(until, onBreak.from)
+			}
+		}
+		return s, until
+	}
+	return nil, 0
+}
+
+// nextOpening is when the till is next staffed after the time given, +Inf if never again.
+func (c *checkout) nextOpening(at float64) float64 {
+	for _, s := range c.roster {
+		if s.to <= at {
+			continue
+		}
+		if s.from > at {
+			return s.from
+		}
+		for _, onBreak := range s.breaks {
+			if at >= onBreak.from && at < onBreak.to {
+				return onBreak.to
+			}
+		}
+		return at
+	}
+	return math.Inf(1)
+}
+
+// closings are the moments the till shuts for a break or because nobody takes over.
+func (c *checkout) closings() []float64 {
+	var closings []float64
+	for i, s := range c.roster {
+		for _, onBreak := range s.breaks {
+			closings = append(closings, onBreak.from)
+		}
+		if math.IsInf(s.to, 1) || (i+1 < len(c.roster) && c.roster[i+1].from == s.to) {
+			continue
+		}
+		closings = append(closings, s.to)
+	}
+	return closings
+}
+
+// scheduleClosings stops the till taking new customers the moment it is due to close,
+// even if the cashier is still finishing off a customer.
+func scheduleClosings(store *store, checkout *checkout) {
+	sim := store.sim
+	for _, at := range checkout.closings() {
+		if at >= sim.engine.now {
+			sim.engine.schedule(at-sim.engine.now, func() { closeTill(store, checkout) })
+		}
+	}
+}
+
+// closeTill puts the "till closed" sign up. The customers in its own line go to the
+// shortest open line they may use, those sharing a line with an open till stay put.
+func closeTill(store *store, till *checkout) {
+	sim := store.sim
+	till.accepting = false
+	sim.logf("Checkout %2d is closing, %s is off the till\n", till.checkoutId, cashierName(till.cashier))
+
+	queue := sim.queues[getQueueIndex(store, till)]
+	for _, kCheckout := range sortedCheckoutKeys(store) {
+		other := store.checkouts[kCheckout]
+		if other.accepting && sim.queues[getQueueIndex(store, other)] == queue {
+			return
+		}
+	}
+
+	for _, eCustomer := range append([]*customer(nil), queue.items...) {
+		var open []*checkout
+		for _, kCheckout := range sortedCheckoutKeys(store) {
+			other := store.checkouts[kCheckout]
+			if !other.selfService && canUseCheckout(store, other, eCustomer) {
+				open = append(open, other)
+			}
+		}
+		target := pickLowest(open, func(c *checkout) float64 {
+			return float64(customersWaiting(store, c))
+		})
+		if target == nil {
+			// Nowhere else to go, they wait for the till to open again.
+			continue
+		}
+		from := store.checkouts["checkout"+strconv.Itoa(eCustomer.checkoutId)]
+		if moveCustomer(store, eCustomer, from, target) {
+			till.reassigned++
+			sim.logf("Customer %4d is sent from Checkout %2d to Checkout %2d\n",
+				eCustomer.customerId, till.checkoutId, target.checkoutId)
+		}
+	}
+}
+
+// startDuty makes sure somebody is at the till before it takes its next customer. A
+// closed till waits for its next shift or the end of the break, a new cashier takes
+// handoverSeconds to log on. It returns false once nobody is working the till again today.
+func startDuty(store *store, checkout *checkout) bool {
+	sim := store.sim
+	current, _ := checkout.onDuty(sim.engine.now)
+	for current == nil {
+		checkout.accepting = false
+		opening := checkout.nextOpening(sim.engine.now)
+		if math.IsInf(opening, 1) {
+			return false
+		}
+		sim.logf("Checkout %2d is closed until %s\n", checkout.checkoutId, formatSimTime(int64(opening)))
+		sim.sleep(opening - sim.engine.now)
+		current, _ = checkout.onDuty(sim.engine.now)
+	}
+
+	if current.cashier != checkout.cashier {
+		if checkout.cashier != "" {
+			sim.logf("Checkout %2d: %s hands over to %s\n",
+				checkout.checkoutId, cashierName(checkout.cashier), cashierName(current.cashier))
+			checkout.handovers++
+			sim.sleep(store.handoverSeconds)
+		}
+		checkout.cashier = current.cashier
+		checkout.cashierEfficiency = current.efficiency
+	}
+	_, checkout.offDutyAt = checkout.onDuty(sim.engine.now)
+	if !checkout.accepting {
+		sim.logf("Checkout %2d is open, %s is on the till\n", checkout.checkoutId, cashierName(checkout.cashier))
+		checkout.accepting = true
+		lineGotShorter(store, checkout)
+	}
+	return true
+}
+
+// nextCustomer is the roster aware get: the till only takes customers while staffed and
+// stops waiting for one when the cashier is due off. nil means the till shuts for the day.
+func nextCustomer(store *store, checkout *checkout) *customer {
+	queue := store.sim.queues[getQueueIndex(store, checkout)]
+	for {
+		if queue.closed && queue.len() == 0 {
+			return nil
+		}
+		if !startDuty(store, checkout) {
+			return nil
+		}
+		if customer := queue.getUntil(checkout.offDutyAt); customer != nil {
+			return customer
+		}
+	}
+}
+
+func cashierName(cashier string) string {
+	if cashier == "" {
+		return "the cashier"
+	}
+	return cashier
+}
+
+// staffedSeconds is how long somebody was on the till between start and end, from the roster.
+func (c *checkout) staffedSeconds(start float64, end float64) float64 {
+	overlap := func(from float64, to float64) float64 {
+		return math.Max(0, math.Min(to, end)-math.Max(from, start))
+	}
+	staffed := 0.0
+	for _, s := range c.roster {
+		staffed += overlap(s.from, s.to)
+		for _, onBreak := range s.breaks {
+			staffed -= overlap(onBreak.from, onBreak.to)
+		}
+	}
+	return staffed
+}
+
+// cashiers are the names on the till's roster, in order.
+func (c *checkout) cashiers() string {
+	var names []string
+	for _, s := range c.roster {
+		if s.cashier != "" && (len(names) == 0 || names[len(names)-1] != s.cashier) {
+			names = append(names, s.cashier)
+		}
+	}
+	if len(c.roster) == 0 {
+		return strings.ToLower(rosterClosed)
+	}
+	if len(names) == 0 {
+		return strings.ToLower(rosterAllDay)
+	}
+	return strings.Join(names, ",")
+}
+
+// printStaffingReport shows how many hours every till was staffed and how many of those
+// the cashier had nobody to serve.
+func printStaffingReport(out io.Writer, sim *simulation, kStore string) {
+	eStore := sim.stores[kStore]
+	start, end := storeWindow(sim, eStore)
+
+	fmt.Fprintf(out, "%-12s %-20s %9s %7s %7s %6s %9s %10s\n",
+		"Staffing", "Cashiers", "Staffed(h)", "Busy(h)", "Idle(h)", "Idle%", "Handovers", "Reassigned")
+	var staffed, busy, idle float64
+	for _, kCheckout := range sortedCheckoutKeys(eStore) {
+		eCheckout := eStore.checkouts[kCheckout]
+		if eCheckout.selfService {
+			continue
+		}
+		checkoutStaffed := eCheckout.staffedSeconds(start, end)
+		// A cashier finishing a customer after the shift ended is not idle time.
+		checkoutIdle := math.Max(0, checkoutStaffed-eCheckout.busySeconds)
+		idlePercent := 0.0
+		if checkoutStaffed > 0 {
+			idlePercent = 100 * checkoutIdle / checkoutStaffed
+		}
+		fmt.Fprintf(out, "%-12s %-20s %9.1f %7.1f %7.1f %6.1f %9d %10d\n",
+			eCheckout.label(), eCheckout.cashiers(), checkoutStaffed/3600, eCheckout.busySeconds/3600,
+			checkoutIdle/3600, idlePercent, eCheckout.handovers, eCheckout.reassigned)
+		staffed += checkoutStaffed
+		busy += eCheckout.busySeconds
+		idle += checkoutIdle
+	}
+	fmt.Fprintf(out, "%-12s %-20s %9.1f %7.1f %7.1f\n", "Total", "", staffed/3600, busy/3600, idle/3600)
+}
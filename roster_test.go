@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestRosterShift(t *testing.T) {
+	// Anna works checkout2 from 10 to 12 with a break at 11, checkout1 is open all day.
+	eStore := runTestDay(t, 7, map[string]string{
+		"openingHours":              "9-13",
+		"busyRange":                 "B",
+		"numberOfCheckouts":         "2",
+		"maxQueueTime":              "1440-1440",
+		"[store1][checkout2]roster": "ANNA@10-12/11:00+15",
+	}).stores["store1"]
+
+	staffed := func(at int64) bool {
+		return at >= 10*3600 && at < 12*3600 && !(at >= 11*3600 && at < 11*3600+15*60)
+	}
+	beforeBreak, afterBreak := 0, 0
+	for _, eCustomer := range sortedCustomers(eStore) {
+		if eCustomer.checkoutId != 2 || eCustomer.checkoutTimeStart == 0 {
+			continue
+		}
+		if !staffed(eCustomer.checkoutTimeStart) {
+			t.Errorf("customer %d was served at checkout2 at %s, while nobody was on the till",
+				eCustomer.customerId, formatSimTime(eCustomer.checkoutTimeStart))
+		} else if eCustomer.checkoutTimeStart < 11*3600 {
+			beforeBreak++
+		} else {
+			afterBreak++
+		}
+	}
+	if beforeBreak == 0 || afterBreak == 0 {
+		t.Errorf("checkout2 served %d customers before Anna's break and %d after, want both", beforeBreak, afterBreak)
+	}
+
+	eCheckout := eStore.checkouts["checkout2"]
+	if got, want := eCheckout.staffedSeconds(9*3600, 13*3600), float64(2*3600-15*60); got != want {
+		t.Errorf("checkout2 staffed for %vs, want %vs", got, want)
+	}
+	if eCheckout.running {
+		t.Errorf("checkout2 is still open after Anna's shift")
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// CheckoutSelector decides which checkout an arriving customer goes to.
+// Every store has one, picked by name from checkoutSelectors.
+type CheckoutSelector interface {
+	// Name is how the strategy is chosen in settings and shown in the journey export.
+	Name() string
+	// SelectCheckout returns the checkout for the customer, only ever one the customer may use,
+	// or nil when no till will take them.
+	SelectCheckout(store *store, customer *customer) *checkout
+}
+
+// checkoutSelectors is the registry of built-in routing strategies.
+var checkoutSelectors = map[string]CheckoutSelector{}
+
+func registerCheckoutSelector(selector CheckoutSelector) {
+	checkoutSelectors[selector.Name()] = selector
+}
+
+func init() {
+	registerCheckoutSelector(randomSelector{})
+	registerCheckoutSelector(shortestQueueSelector{})
+	registerCheckoutSelector(fewestItemsSelector{})
+	registerCheckoutSelector(shortestWorkloadSelector{})
+	registerCheckoutSelector(desirabilitySelector{})
+	registerCheckoutSelector(nearestEntranceSelector{})
+}
+
+func checkoutSelectorNames() []string {
+	var names []string
+	for name := range checkoutSelectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// canUseCheckout is the one place that decides if a customer is allowed at a checkout,
+// every strategy goes through it. The till has to be open (see roster.go), the basket
+// within the item limit of the moment and the lane has to take the customer's way of
+// paying (see lanes.go).
+func canUseCheckout(store *store, checkout *checkout, customer *customer) bool {
+	return checkout.accepting &&
+		withinItemLimit(checkout.itemLimit(store.sim.engine.now), customer) &&
+		checkout.lane.takesPayment(customer.paymentMethod)
+}
+
+// eligibleCheckouts are the staffed checkouts the customer may use, nearest the entrance
+// first, only the accessible ones if the customer needs one and may use them. If no open
+// checkout takes that many items the customer is let through at any open one, and if every
+// till is closed they wait at one for it to open. It is empty when no till will open again.
+func eligibleCheckouts(store *store, customer *customer) []*checkout {
+	var eligible, open, all []*checkout
+	for _, kCheckout := range sortedCheckoutKeys(store) {
+		eCheckout := store.checkouts[kCheckout]
+		if eCheckout.selfService {
+			continue
+		}
+		if !math.IsInf(eCheckout.nextOpening(store.sim.engine.now), 1) {
+			all = append(all, eCheckout)
+		}
+		if eCheckout.accepting {
+			open = append(open, eCheckout)
+		}
+		if canRouteTo(store, eCheckout, customer) {
+			eligible = append(eligible, eCheckout)
+		}
+	}
+	switch {
+	case len(eligible) > 0:
+		return eligible
+	case len(open) > 0:
+		return open
+	}
+	return all
+}
+
+// itemsWaiting counts the items in the line plus those of the customer being served.
+func itemsWaiting(store *store, checkout *checkout) int {
+	items := 0
+	for _, eCustomer := range store.sim.queues[getQueueIndex(store, checkout)].items {
+		items += eCustomer.items
+	}
+	if checkout.serving != nil {
+		items += checkout.serving.items
+	}
+	return items
+}
+
+// customersWaiting counts the customers in the line plus the one being served.
+func customersWaiting(store *store, checkout *checkout) int {
+	customers := store.sim.queues[getQueueIndex(store, checkout)].len()
+	if checkout.serving != nil {
+		customers++
+	}
+	return customers
+}
+
+// expectedWorkload is how many seconds this checkout needs to get through everybody in
+// front of a new customer, using the average scan time and the cashier efficiency.
+func expectedWorkload(store *store, checkout *checkout) float64 {
+	meanScanSeconds := (store.productProcessTime.from + store.productProcessTime.to) / 2
+	scanning := float64(itemsWaiting(store, checkout)) * meanScanSeconds * checkout.cashierEfficiency
+	paying := float64(customersWaiting(store, checkout)) * (store.payments.expectedSeconds() + checkoutChangeoverSeconds)
+	return scanning + paying
+}
+
+// acceptsQueue is the customer looking at the line: fewer people than maxQueueCustomers
+// and, if the customer counts them, fewer items than maxQueueItems.
+func acceptsQueue(store *store, checkout *checkout, customer *customer) bool {
+	if customersWaiting(store, checkout) >= customer.maxQueueCustomers {
+		return false
+	}
+	return customer.maxQueueItems == 0 || itemsWaiting(store, checkout) < customer.maxQueueItems
+}
+
+// findAcceptableCheckout is the shortest line the customer would still join, or nil if
+// every line is too long for them.
+func findAcceptableCheckout(store *store, customer *customer) *checkout {
+	var acceptable []*checkout
+	for _, eCheckout := range eligibleCheckouts(store, customer) {
+		if acceptsQueue(store, eCheckout, customer) {
+			acceptable = append(acceptable, eCheckout)
+		}
+	}
+	return pickLowest(acceptable, func(c *checkout) float64 {
+		return float64(customersWaiting(store, c))
+	})
+}
+
+// pickLowest returns the first checkout with the lowest score, so ties go to the one nearest the entrance.
+func pickLowest(checkouts []*checkout, score func(*checkout) float64) *checkout {
+	var best *checkout
+	bestScore := 0.0
+	for _, eCheckout := range checkouts {
+		s := score(eCheckout)
+		if best == nil || s < bestScore {
+			best = eCheckout
+			bestScore = s
+		}
+	}
+	return best
+}
+
+// firstEligible is the eligible checkout nearest the entrance, nil if there is none.
+func firstEligible(store *store, customer *customer) *checkout {
+	if checkouts := eligibleCheckouts(store, customer); len(checkouts) > 0 {
+		return checkouts[0]
+	}
+	return nil
+}
+
+// randomSelector sends the customer to any checkout they may use.
+type randomSelector struct{}
+
+func (randomSelector) Name() string { return "random" }
+
+func (randomSelector) SelectCheckout(store *store, customer *customer) *checkout {
+	if checkout := getCheckoutRandomly(store, customer); checkout != nil {
+		return checkout
+	}
+	return firstEligible(store, customer)
+}
+
+// shortestQueueSelector is what the floor manager does, the line with fewest people.
+type shortestQueueSelector struct{}
+
+func (shortestQueueSelector) Name() string { return "shortest-queue" }
+
+func (shortestQueueSelector) SelectCheckout(store *store, customer *customer) *checkout {
+	if checkout := getCheckoutWithShorterQueue(store, customer); checkout != nil {
+		return checkout
+	}
+	return firstEligible(store, customer)
+}
+
+// fewestItemsSelector looks into the trolleys and picks the line with fewest items.
+type fewestItemsSelector struct{}
+
+func (fewestItemsSelector) Name() string { return "fewest-items" }
+
+func (fewestItemsSelector) SelectCheckout(store *store, customer *customer) *checkout {
+	return pickLowest(eligibleCheckouts(store, customer), func(c *checkout) float64 {
+		return float64(itemsWaiting(store, c))
+	})
+}
+
+// shortestWorkloadSelector also takes into account how fast each cashier is.
+type shortestWorkloadSelector struct{}
+
+func (shortestWorkloadSelector) Name() string { return "shortest-workload" }
+
+func (shortestWorkloadSelector) SelectCheckout(store *store, customer *customer) *checkout {
+	return pickLowest(eligibleCheckouts(store, customer), func(c *checkout) float64 {
+		return expectedWorkload(store, c)
+	})
+}
+
+// desirabilitySelector picks at random, with checkoutDesirability as the weight.
+type desirabilitySelector struct{}
+
+func (desirabilitySelector) Name() string { return "desirability" }
+
+func (desirabilitySelector) SelectCheckout(store *store, customer *customer) *checkout {
+	checkouts := eligibleCheckouts(store, customer)
+	if len(checkouts) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, eCheckout := range checkouts {
+		total += eCheckout.checkoutDesirability
+	}
+	if total == 0 {
+		// Nobody likes any of them more than the others.
+		return checkouts[generateRandomNumber(store.rng.routing, 0, len(checkouts)-1)]
+	}
+
+	pick := generateRandomNumber(store.rng.routing, 1, total)
+	for _, eCheckout := range checkouts {
+		pick -= eCheckout.checkoutDesirability
+		if pick <= 0 {
+			return eCheckout
+		}
+	}
+	return checkouts[len(checkouts)-1]
+}
+
+// nearestEntranceSelector walks from the entrance (checkout 1) and joins the first line
+// the customer would accept, or the last one if none.
+type nearestEntranceSelector struct{}
+
+func (nearestEntranceSelector) Name() string { return "nearest-entrance" }
+
+func (nearestEntranceSelector) SelectCheckout(store *store, customer *customer) *checkout {
+	checkouts := eligibleCheckouts(store, customer)
+	if len(checkouts) == 0 {
+		return nil
+	}
+	for _, eCheckout := range checkouts {
+		if acceptsQueue(store, eCheckout, customer) {
+			return eCheckout
+		}
+	}
+	return checkouts[len(checkouts)-1]
+}
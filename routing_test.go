@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+// A store where the only till is closed for the rest of the day.
+func closedStore() *store {
+	eStore := &store{
+		storeId:   1,
+		checkouts: map[string]*checkout{"checkout1": {checkoutId: 1}},
+		rng:       newRandomStreams(1, 1),
+	}
+	newSimulation(&dualTimeClock{}, false, map[string]*store{"store1": eStore})
+	return eStore
+}
+
+func TestSelectorsWithNoEligibleCheckout(t *testing.T) {
+	for _, name := range checkoutSelectorNames() {
+		eStore := closedStore()
+		eCustomer := &customer{customerId: 1, items: 5, maxQueueCustomers: 5}
+		if got := checkoutSelectors[name].SelectCheckout(eStore, eCustomer); got != nil {
+			t.Errorf("%s: SelectCheckout = checkout %d, want nil", name, got.checkoutId)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// selfCheckoutSettings describe the bank of self-service kiosks of a store. There is one
+// line for the whole bank and one attendant looking after all the kiosks.
+type selfCheckoutSettings struct {
+	kiosks int
+	// maxItems is the most items a customer may bring to a kiosk, 0 means no limit.
+	maxItems int
+	// scanFactor is how much slower a customer scans than a cashier with efficiency 1.
+	scanFactor float64
+	// preference is the chance a customer would rather use a kiosk than a staffed lane.
+	preference float64
+	// unexpectedItemChance is per item, ageCheckChance per customer.
+	unexpectedItemChance float64
+	ageCheckChance       float64
+	// interventionTime is how long the attendant takes once at the kiosk, in seconds.
+	interventionTime floatRange
+}
+
+func readSelfCheckoutSettings(iStore int, defaultSettingsCode string) (selfCheckoutSettings, error) {
+	var settings selfCheckoutSettings
+	prefix := "[Store " + strconv.Itoa(iStore) + "] "
+	code := "[store" + strconv.Itoa(iStore) + "]"
+
+	var err error
+	settings.kiosks, err = readInt(
+		prefix+"How many self-service kiosks will this store have? 0 means none [0] ",
+		"0",
+		defaultSettingsCode,
+		code+"selfCheckouts",
+		0, 1000)
+	if err != nil || settings.kiosks == 0 {
+		return settings, err
+	}
+
+	settings.maxItems, err = readInt(
+		prefix+"Maximum items for the self-service kiosks? 0 means unlimited [20] ",
+		"20",
+		defaultSettingsCode,
+		code+"selfCheckoutMaxItems",
+		0, 10000)
+	if err != nil {
+		return settings, err
+	}
+	settings.scanFactor, err = readPositiveFloat(
+		prefix+"How much slower do customers scan than a cashier? [1.5] means it takes them one and a half times as long ",
+		"1.5",
+		defaultSettingsCode,
+		code+"selfScanFactor",
+		10)
+	if err != nil {
+		return settings, err
+	}
+	settings.preference, err = readFloat(
+		prefix+"How likely is a customer to prefer a kiosk to a staffed checkout? From 0 to 1 [0.4] ",
+		"0.4",
+		defaultSettingsCode,
+		code+"selfCheckoutPreference",
+		0, 1)
+	if err != nil {
+		return settings, err
+	}
+	settings.unexpectedItemChance, err = readFloat(
+		prefix+"How likely is an \"unexpected item in bagging area\" for every item scanned? From 0 to 1 [0.02] ",
+		"0.02",
+		defaultSettingsCode,
+		code+"unexpectedItemChance",
+		0, 1)
+	if err != nil {
+		return settings, err
+	}
+	settings.ageCheckChance, err = readFloat(
+		prefix+"How likely does a customer need their age checked at the kiosk? From 0 to 1 [0.05] ",
+		"0.05",
+		defaultSettingsCode,
+		code+"ageCheckChance",
+		0, 1)
+	if err != nil {
+		return settings, err
+	}
+	settings.interventionTime, err = readFloatRange(
+		prefix+"How long does the attendant take to sort out a kiosk? Range response in seconds [20-60] ",
+		"20-60",
+		defaultSettingsCode,
+		code+"interventionTime",
+		0, 3600)
+	return settings, err
+}
+
+// addKiosks puts the kiosks in with the checkouts, numbered after the staffed ones, so
+// they get a process, a line and statistics like every other checkout.
+func addKiosks(checkouts map[string]*checkout, settings selfCheckoutSettings) {
+	firstId := len(checkouts) + 1
+	for iKiosk := 0; iKiosk < settings.kiosks; iKiosk++ {
+		checkouts["checkout"+strconv.Itoa(firstId+iKiosk)] = &checkout{
+			checkoutId:           firstId + iKiosk,
+			selfService:          true,
+			kioskId:              iKiosk + 1,
+			cashierEfficiency:    settings.scanFactor,
+			maxItems:             settings.maxItems,
+			roster:               []shift{{from: 0, to: math.Inf(1)}},
+			currentDeep:          SafeCounter{v: 0},
+			status:               "IDLE",
+			totalItemsCheckedOut: SafeCounter{v: 0},
+			totalCustomersServed: SafeCounter{v: 0},
+		}
+	}
+}
+
+// label is how a checkout is shown in the reports.
+func (c *checkout) label() string {
+	if c.selfService {
+		return "kiosk" + strconv.Itoa(c.kioskId)
+	}
+	return "checkout" + strconv.Itoa(c.checkoutId)
+}
+
+// helper is a member of staff several tills call for, such as the self-checkout attendant.
+// They deal with one call at a time, first come first served.
+type helper struct {
+	freeAt      float64
+	calls       map[string]int
+	busySeconds float64
+	waitSeconds float64
+}
+
+func newHelper() *helper {
+	return &helper{calls: map[string]int{}}
+}
+
+// call makes the calling process wait until the helper has come over and dealt with it.
+func (h *helper) call(sim *simulation, reason string, seconds float64) {
+	start := math.Max(sim.engine.now, h.freeAt)
+	h.freeAt = start + seconds
+	h.calls[reason]++
+	h.busySeconds += seconds
+	h.waitSeconds += start - sim.engine.now
+	sim.sleep(h.freeAt - sim.engine.now)
+}
+
+func (h *helper) totalCalls() int {
+	total := 0
+	for _, calls := range h.calls {
+		total += calls
+	}
+	return total
+}
+
+// selfScan is the customer scanning their own shopping at a kiosk, calling the attendant
+// for the age check and whenever the kiosk complains about the bagging area.
+func selfScan(store *store, kiosk *checkout, customer *customer) {
+	sim := store.sim
+	settings := store.selfCheckout
+	interventionTime := func() float64 {
+		return settings.interventionTime.from +
+			customer.rng.selfCheckout.Float64()*(settings.interventionTime.to-settings.interventionTime.from)
+	}
+
+	needsAgeCheck := customer.rng.selfCheckout.Float64() < settings.ageCheckChance
+	if store.catalogue != nil {
+		// The catalogue knows which items need an age check.
+		needsAgeCheck = customer.hasAgeRestrictedItem()
+	}
+	if needsAgeCheck {
+		sim.logf("Kiosk %2d: customer %4d needs an age check, calling the attendant\n", kiosk.kioskId, customer.customerId)
+		store.attendant.call(sim, "age check", interventionTime())
+	}
+
+	for _, eProduct := range sortedProducts(customer) {
+		kiosk.scanProduct(sim, customer, &eProduct)
+		if customer.rng.selfCheckout.Float64() < settings.unexpectedItemChance {
+			sim.logf("Kiosk %2d: unexpected item in bagging area, calling the attendant\n", kiosk.kioskId)
+			store.attendant.call(sim, "unexpected item", interventionTime())
+		}
+	}
+}
+
+// chooseSelfCheckout sends the customer to the kiosks if they like them, their basket is
+// small enough and the line is one they would join. Otherwise it returns nil.
+func chooseSelfCheckout(store *store, customer *customer) *checkout {
+	if !customer.prefersSelfCheckout {
+		return nil
+	}
+	var kiosks []*checkout
+	for _, kCheckout := range sortedCheckoutKeys(store) {
+		eCheckout := store.checkouts[kCheckout]
+		if eCheckout.selfService && canUseCheckout(store, eCheckout, customer) && acceptsQueue(store, eCheckout, customer) {
+			kiosks = append(kiosks, eCheckout)
+		}
+	}
+	return pickLowest(kiosks, func(c *checkout) float64 {
+		return float64(customersWaiting(store, c))
+	})
+}
+
+// printSelfCheckoutReport shows how busy the kiosks and their attendant were.
+func printSelfCheckoutReport(out io.Writer, sim *simulation, kStore string) {
+	eStore := sim.stores[kStore]
+	if eStore.selfCheckout.kiosks == 0 {
+		return
+	}
+	start, end := storeWindow(sim, eStore)
+
+	served, busy := 0, 0.0
+	for _, eCheckout := range eStore.checkouts {
+		if eCheckout.selfService {
+			served += eCheckout.totalCustomersServed.Value()
+			busy += eCheckout.busySeconds
+		}
+	}
+	attendant := eStore.attendant
+	meanWait := 0.0
+	if attendant.totalCalls() > 0 {
+		meanWait = attendant.waitSeconds / float64(attendant.totalCalls())
+	}
+
+	fmt.Fprintf(out, "Self-checkout: %d kiosks served %d customers, utilisation %.1f%%\n",
+		eStore.selfCheckout.kiosks, served, 100*busy/(float64(eStore.selfCheckout.kiosks)*(end-start)))
+	fmt.Fprintf(out, "Attendant: %d calls (%d unexpected item, %d age check), busy %.1f%%, mean wait for the attendant %.0f s\n",
+		attendant.totalCalls(), attendant.calls["unexpected item"], attendant.calls["age check"],
+		100*attendant.busySeconds/(end-start), meanWait)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// shoppingDuration is how many seconds a customer spends in the aisles before they get to
+// the checkouts. Every item takes a while to find, how long is drawn once per customer from
+// perItem, and busy aisles slow everybody down by the busy factor of the hour they came in.
+func shoppingDuration(stream *rand.Rand, perItem floatRange, items int, busyRanges map[string]busyRange,
+	arrival float64) float64 {
+
+	secondsPerItem := perItem.from + stream.Float64()*(perItem.to-perItem.from)
+	busyFactor := busyRanges["busyRange_"+strconv.Itoa(int(arrival/3600))].busyOptionFactor.factor
+	if busyFactor == 0 {
+		// Not an hour we were asked about.
+		busyFactor = 1
+	}
+	return float64(items) * secondsPerItem * float64(busyFactor)
+}
+
+// inStoreCount is how many customers were in the store, shopping or at the checkouts,
+// at the given second of the day.
+func inStoreCount(store *store, at int64) int {
+	count := 0
+	for _, eCustomer := range store.customers {
+		if eCustomer.outcome == "" {
+			continue
+		}
+		if eCustomer.arrivalTime <= at && at < eCustomer.departureTime {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// simulation holds everything that belongs to one simulated trading day:
+// the clock, the event engine, the stores and their queues.
+type simulation struct {
+	clock  *dualTimeClock
+	engine *simEngine
+	stores map[string]*store
+	queues map[string]*simQueue
+	out    io.Writer
+
+	// openCheckouts counts the checkouts of every store that have not shut yet,
+	// when it gets to 0 the day is over and stop ends the run.
+	openCheckouts int
+	stop          context.CancelFunc
+}
+
+func newSimulation(clock *dualTimeClock, realTime bool, stores map[string]*store) *simulation {
+	sim := &simulation{
+		clock:  clock,
+		engine: newSimEngine(clock, realTime),
+		stores: stores,
+		queues: map[string]*simQueue{},
+		out:    os.Stdout,
+	}
+
+	for _, kStore := range sortedStoreKeys(stores) {
+		eStore := stores[kStore]
+		eStore.sim = sim
+		// Checkouts in the same group share one line, see topology.go.
+		groups := map[string]*simQueue{}
+		for _, kCheckout := range sortedCheckoutKeys(eStore) {
+			eCheckout := eStore.checkouts[kCheckout]
+			group := queueGroup(eStore, eCheckout)
+			if groups[group] == nil {
+				groups[group] = newSimQueue(sim.engine)
+			}
+			sim.queues[getQueueIndex(eStore, eCheckout)] = groups[group]
+		}
+	}
+
+	return sim
+}
+
+// logf prints a line prefixed with the simulated time of day.
+func (sim *simulation) logf(format string, a ...interface{}) {
+	_, simWorldCurrentTimeString := sim.clock.getSimWorldCurrentTime()
+	fmt.Fprintf(sim.out, simWorldCurrentTimeString+":"+format, a...)
+}
+
+// sleep lets the calling process wait for the given simulated seconds.
+func (sim *simulation) sleep(seconds float64) {
+	sim.engine.sleep(seconds)
+}
+
+// run opens every checkout, starts the customers arriving and plays the day out. It
+// returns once every checkout has shut after closing time, or ctx's error if ctx is
+// cancelled first.
+func (sim *simulation) run(ctx context.Context) error {
+	if !sim.engine.realTime {
+		fmt.Fprintln(sim.out, "Running as a discrete-event simulation, no real time will be waited.")
+	}
+	sim.clock.initRealWorldStartTime()
+
+	for _, kStore := range sortedStoreKeys(sim.stores) {
+		eStore := sim.stores[kStore]
+		for _, kCheckout := range sortedCheckoutKeys(eStore) {
+			eCheckout := eStore.checkouts[kCheckout]
+			if eCheckout.standby {
+				// Opened by the floor manager when needed.
+				continue
+			}
+			spawnCheckout(eStore, kCheckout, eCheckout)
+		}
+	}
+
+	for _, kStore := range sortedStoreKeys(sim.stores) {
+		eStore := sim.stores[kStore]
+		sim.engine.spawn(kStore, func() { customerSpawning(eStore) })
+		sim.engine.spawn(kStore+" closing", func() { closeStore(eStore) })
+		if eStore.hasFloorManager && eStore.floorManager.reserveStaff > 0 {
+			sim.engine.spawn(kStore+" floor manager", func() { floorManager(eStore) })
+		}
+	}
+
+	dayCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	sim.stop = stop
+
+	if err := sim.engine.run(dayCtx); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// spawnCheckout starts the process of a checkout, it counts as open until it shuts.
+func spawnCheckout(eStore *store, kCheckout string, eCheckout *checkout) {
+	sim := eStore.sim
+	sim.openCheckouts++
+	eCheckout.running = true
+	sim.engine.spawn(kCheckout, func() { openCheckout(eStore, kCheckout, eCheckout) })
+}
+
+// checkoutShut is called by a checkout that has gone home, the last one ends the day.
+func (sim *simulation) checkoutShut() {
+	sim.openCheckouts--
+	if sim.openCheckouts == 0 {
+		sim.stop()
+	}
+}
+
+// Maps are iterated in a random order in Go, we always walk them sorted so that
+// the same input gives the same day.
+func sortedStoreKeys(stores map[string]*store) []string {
+	keys := make([]string, 0, len(stores))
+	for kStore := range stores {
+		keys = append(keys, kStore)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return stores[keys[i]].storeId < stores[keys[j]].storeId
+	})
+	return keys
+}
+
+func sortedCheckoutKeys(store *store) []string {
+	keys := make([]string, 0, len(store.checkouts))
+	for kCheckout := range store.checkouts {
+		keys = append(keys, kCheckout)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return store.checkouts[keys[i]].checkoutId < store.checkouts[keys[j]].checkoutId
+	})
+	return keys
+}
+
+func sortedCustomers(store *store) []*customer {
+	customers := make([]*customer, 0, len(store.customers))
+	for _, eCustomer := range store.customers {
+		customers = append(customers, eCustomer)
+	}
+	sort.Slice(customers, func(i, j int) bool {
+		return customers[i].customerId < customers[j].customerId
+	})
+	return customers
+}
+
+func sortedProducts(customer *customer) []product {
+	products := make([]product, 0, len(customer.products))
+	for _, eProduct := range customer.products {
+		products = append(products, eProduct)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].productId < products[j].productId
+	})
+	return products
+}
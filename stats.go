@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// distribution summarises a list of durations in seconds.
+type distribution struct {
+	count    int
+	mean     float64
+	variance float64
+	median   float64
+	p90      float64
+	p99      float64
+}
+
+func summarise(values []float64) distribution {
+	if len(values) == 0 {
+		return distribution{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	total := 0.0
+	for _, v := range sorted {
+		total += v
+	}
+	mean := total / float64(len(sorted))
+
+	// Population variance, we have every customer of the day and not a sample.
+	squares := 0.0
+	for _, v := range sorted {
+		squares += (v - mean) * (v - mean)
+	}
+
+	return distribution{
+		count:    len(sorted),
+		mean:     mean,
+		variance: squares / float64(len(sorted)),
+		median:   percentile(sorted, 50),
+		p90:      percentile(sorted, 90),
+		p99:      percentile(sorted, 99),
+	}
+}
+
+// percentile uses the nearest rank method on an already sorted list.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// checkoutStatistics are the numbers a store manager looks at for one till.
+type checkoutStatistics struct {
+	name            string
+	served          int
+	wait            distribution
+	meanService     float64
+	perHour         float64
+	utilisation     float64
+	meanQueueLength float64
+	maxQueueLength  int
+}
+
+// hourStatistics is the arrivals, against what the arrival rate expected, and the
+// abandonment for customers arriving in one hour of the day. toCheckouts are those that
+// finished shopping in that hour, inStore and peakInStore count everybody in the store.
+type hourStatistics struct {
+	hour        int
+	expected    float64
+	arrivals    int
+	abandoned   int
+	toCheckouts int
+	inStore     int
+	peakInStore int
+}
+
+type storeStatistics struct {
+	name            string
+	routing         string
+	topology        string
+	fairness        fairness
+	openSeconds     float64
+	served          int
+	reneged         int
+	balked          int
+	turnedAway      int
+	paymentFailed   int
+	joined          int
+	otherLane       int
+	wait            distribution
+	meanService     float64
+	perHour         float64
+	meanQueueLength float64
+	maxQueueLength  int
+	checkouts       []checkoutStatistics
+	hours           []hourStatistics
+}
+
+// storeWindow is the part of the day the statistics cover: from opening until closing,
+// or until the last customer left if that was later.
+func storeWindow(sim *simulation, eStore *store) (float64, float64) {
+	start := float64(eStore.openingHoursFrom * 3600)
+	end := math.Max(float64(eStore.openingHoursTo*3600), sim.engine.now)
+	return start, end
+}
+
+func collectStatistics(sim *simulation, kStore string) storeStatistics {
+	eStore := sim.stores[kStore]
+	start, end := storeWindow(sim, eStore)
+	openHours := (end - start) / 3600
+
+	stats := storeStatistics{name: kStore, routing: eStore.checkoutSelector.Name(),
+		topology: eStore.queueTopology, openSeconds: end - start}
+
+	waitsByCheckout := map[int][]float64{}
+	servicesByCheckout := map[int][]float64{}
+	var waits, services []float64
+	hours := map[int]*hourStatistics{}
+	for hour, expected := range eStore.expectedArrivals {
+		hours[hour] = &hourStatistics{hour: hour, expected: expected}
+	}
+
+	hourOf := func(hour int) *hourStatistics {
+		if hours[hour] == nil {
+			hours[hour] = &hourStatistics{hour: hour}
+		}
+		return hours[hour]
+	}
+	lastDeparture := int64(0)
+
+	for _, eCustomer := range sortedCustomers(eStore) {
+		hour := int(eCustomer.scheduledArrival / 3600)
+		hourOf(hour).arrivals++
+		if eCustomer.outcome != "" {
+			hourOf(int(eCustomer.reachedCheckouts/3600)).toCheckouts++
+			if eCustomer.departureTime > lastDeparture {
+				lastDeparture = eCustomer.departureTime
+			}
+		}
+
+		switch eCustomer.arrivalDecision {
+		case arrivalJoined:
+			stats.joined++
+		case arrivalOtherLane:
+			stats.otherLane++
+		}
+
+		switch eCustomer.outcome {
+		case outcomeServed:
+			stats.served++
+			waits = append(waits, float64(eCustomer.queueTimeSeconds))
+			services = append(services, float64(eCustomer.checkoutTime))
+			waitsByCheckout[eCustomer.checkoutId] = append(waitsByCheckout[eCustomer.checkoutId], float64(eCustomer.queueTimeSeconds))
+			servicesByCheckout[eCustomer.checkoutId] = append(servicesByCheckout[eCustomer.checkoutId], float64(eCustomer.checkoutTime))
+		case outcomeReneged:
+			stats.reneged++
+			hours[hour].abandoned++
+		case outcomeBalked:
+			stats.balked++
+			hours[hour].abandoned++
+		case outcomeTurnedAway:
+			stats.turnedAway++
+			hours[hour].abandoned++
+		case outcomePaymentFailed:
+			stats.paymentFailed++
+			hours[hour].abandoned++
+		}
+	}
+
+	stats.wait = summarise(waits)
+	stats.meanService = summarise(services).mean
+	if openHours > 0 {
+		stats.perHour = float64(stats.served) / openHours
+	}
+
+	for _, kCheckout := range sortedCheckoutKeys(eStore) {
+		eCheckout := eStore.checkouts[kCheckout]
+		queue := sim.queues[getQueueIndex(eStore, eCheckout)]
+
+		checkoutStats := checkoutStatistics{
+			name:            eCheckout.label(),
+			served:          eCheckout.totalCustomersServed.Value(),
+			wait:            summarise(waitsByCheckout[eCheckout.checkoutId]),
+			meanService:     summarise(servicesByCheckout[eCheckout.checkoutId]).mean,
+			meanQueueLength: queue.meanLength(end),
+			maxQueueLength:  queue.maxLength,
+		}
+		if openHours > 0 {
+			checkoutStats.perHour = float64(checkoutStats.served) / openHours
+		}
+		// Busy of the time somebody was on the till, like the staffing report.
+		if staffed := eCheckout.staffedSeconds(start, end); staffed > 0 {
+			checkoutStats.utilisation = 100 * eCheckout.busySeconds / staffed
+		}
+
+		stats.checkouts = append(stats.checkouts, checkoutStats)
+	}
+
+	// A shared line is counted once, not once for every checkout it feeds.
+	queues := storeQueues(sim, eStore)
+	for _, queue := range queues {
+		stats.meanQueueLength += queue.meanLength(end)
+		if queue.maxLength > stats.maxQueueLength {
+			stats.maxQueueLength = queue.maxLength
+		}
+	}
+	if len(queues) > 0 {
+		stats.meanQueueLength = stats.meanQueueLength / float64(len(queues))
+	}
+	stats.fairness = waitFairness(eStore)
+
+	for hour := eStore.openingHoursFrom; int64(hour*3600) <= lastDeparture; hour++ {
+		eHour := hourOf(hour)
+		eHour.inStore = inStoreCount(eStore, int64(hour*3600))
+		for minute := 0; minute < 60; minute++ {
+			if count := inStoreCount(eStore, int64(hour*3600+minute*60)); count > eHour.peakInStore {
+				eHour.peakInStore = count
+			}
+		}
+	}
+
+	for _, eHour := range hours {
+		stats.hours = append(stats.hours, *eHour)
+	}
+	sort.Slice(stats.hours, func(i, j int) bool { return stats.hours[i].hour < stats.hours[j].hour })
+
+	return stats
+}
+
+func minutes(seconds float64) float64 {
+	return seconds / 60
+}
+
+// printStatistics is the end of day report with the queueing numbers for every store and checkout.
+func printStatistics(out io.Writer, sim *simulation) {
+	for _, kStore := range sortedStoreKeys(sim.stores) {
+		stats := collectStatistics(sim, kStore)
+
+		fmt.Fprintf(out, "===Store: %s statistics (%.1f hours, routing %s)\n", stats.name, stats.openSeconds/3600, stats.routing)
+		fmt.Fprintf(out, "Served: %d, reneged: %d, balked: %d, turned away at closing: %d\n",
+			stats.served, stats.reneged, stats.balked, stats.turnedAway)
+		fmt.Fprintf(out, "On arrival: joined the line %d, went to another line %d, left the store %d\n",
+			stats.joined, stats.otherLane, stats.balked)
+		fmt.Fprintf(out, "Wait (min): mean %.1f, median %.1f, p90 %.1f, p99 %.1f\n",
+			minutes(stats.wait.mean), minutes(stats.wait.median), minutes(stats.wait.p90), minutes(stats.wait.p99))
+		fmt.Fprintf(out, "Service (min): mean %.1f\n", minutes(stats.meanService))
+		fmt.Fprintf(out, "Throughput: %.1f customers/hour\n", stats.perHour)
+		fmt.Fprintf(out, "Queue length (%s): mean %.2f per line, max %d\n",
+			strings.ToLower(stats.topology), stats.meanQueueLength, stats.maxQueueLength)
+		fmt.Fprintf(out, "Fairness: wait sd %.1f min, Jain index %.2f, overtaken in the line by a later customer %.1f%%\n",
+			minutes(math.Sqrt(stats.wait.variance)), stats.fairness.jainIndex, stats.fairness.overtaken)
+
+		fmt.Fprintf(out, "%-12s %6s %6s %6s %6s %6s %8s %6s %6s %7s %5s\n",
+			"Checkout", "Served", "Wait", "Median", "p90", "p99", "Service", "/hour", "Util%", "Queue", "Max")
+		for _, c := range stats.checkouts {
+			fmt.Fprintf(out, "%-12s %6d %6.1f %6.1f %6.1f %6.1f %8.1f %6.1f %6.1f %7.2f %5d\n",
+				c.name, c.served, minutes(c.wait.mean), minutes(c.wait.median), minutes(c.wait.p90), minutes(c.wait.p99),
+				minutes(c.meanService), c.perHour, c.utilisation, c.meanQueueLength, c.maxQueueLength)
+		}
+		printStaffingReport(out, sim, kStore)
+		printLaneReport(out, sim.stores[kStore])
+		printCatalogueReport(out, sim.stores[kStore])
+		printExceptionReport(out, sim, kStore)
+		printSelfCheckoutReport(out, sim, kStore)
+		printPaymentReport(out, sim.stores[kStore])
+
+		fmt.Fprintf(out, "%-6s %8s %8s %9s %8s %9s %7s %5s\n",
+			"Hour", "Expected", "Arrivals", "Abandoned", "Rate%", "Checkouts", "InStore", "Peak")
+		totalExpected, totalArrivals := 0.0, 0
+		for _, h := range stats.hours {
+			rate := 0.0
+			if h.arrivals > 0 {
+				rate = 100 * float64(h.abandoned) / float64(h.arrivals)
+			}
+			fmt.Fprintf(out, "%02d:00  %8.1f %8d %9d %8.1f %9d %7d %5d\n",
+				h.hour, h.expected, h.arrivals, h.abandoned, rate, h.toCheckouts, h.inStore, h.peakInStore)
+			totalExpected += h.expected
+			totalArrivals += h.arrivals
+		}
+		fmt.Fprintf(out, "%-6s %8.1f %8d\n", "Total", totalExpected, totalArrivals)
+	}
+}
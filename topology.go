@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// How the lines in front of the checkouts of a store are laid out.
+const (
+	// Every checkout has its own line, how it always was.
+	topologyPerLane = "PER-LANE"
+	// One snake line for all the checkouts without an item limit, a "next till please"
+	// sends the customer at the front to whichever till is free. Express checkouts keep
+	// their own line, and so do checkouts with lane rules (see lanes.go).
+	topologyShared = "SHARED"
+	// Like shared, plus a second snake line for the express checkouts (one per item limit).
+	topologyHybrid = "HYBRID"
+)
+
+// queueGroup names the line a checkout takes its customers from. Checkouts with the same
+// group share one simQueue.
+func queueGroup(store *store, checkout *checkout) string {
+	storePrefix := "store_" + strconv.Itoa(store.storeId)
+	switch {
+	case checkout.selfService:
+		// The kiosks always have one line for the whole bank.
+		return storePrefix + "_self"
+	case checkout.lane.restricted():
+		// A shared line would let any till serve whoever is at the front.
+		return getQueueIndex(store, checkout)
+	case store.queueTopology == topologyShared && checkout.maxItems == 0,
+		store.queueTopology == topologyHybrid && checkout.maxItems == 0:
+		return storePrefix + "_main"
+	case store.queueTopology == topologyHybrid:
+		return storePrefix + "_express_" + strconv.Itoa(checkout.maxItems)
+	}
+	return getQueueIndex(store, checkout)
+}
+
+// storeQueues are the distinct lines of a store, in checkout order.
+func storeQueues(sim *simulation, store *store) []*simQueue {
+	var queues []*simQueue
+	seen := map[*simQueue]bool{}
+	for _, kCheckout := range sortedCheckoutKeys(store) {
+		queue := sim.queues[getQueueIndex(store, store.checkouts[kCheckout])]
+		if !seen[queue] {
+			seen[queue] = true
+			queues = append(queues, queue)
+		}
+	}
+	return queues
+}
+
+// fairness is how evenly the wait is shared out between the customers of a store.
+type fairness struct {
+	// jainIndex is 1 when everybody waits the same and goes to 1/n when one customer
+	// does all the waiting.
+	jainIndex float64
+	// overtaken is the percentage of served customers that saw somebody who reached the
+	// checkouts after them get to a till first.
+	overtaken float64
+}
+
+func waitFairness(store *store) fairness {
+	var served []*customer
+	for _, eCustomer := range sortedCustomers(store) {
+		if eCustomer.outcome == outcomeServed {
+			served = append(served, eCustomer)
+		}
+	}
+	if len(served) == 0 {
+		return fairness{}
+	}
+
+	sum, squares := 0.0, 0.0
+	for _, eCustomer := range served {
+		wait := float64(eCustomer.queueTimeSeconds)
+		sum += wait
+		squares += wait * wait
+	}
+	result := fairness{jainIndex: 1}
+	if squares > 0 {
+		result.jainIndex = sum * sum / (float64(len(served)) * squares)
+	}
+
+	// Walk from the last customer to reach the checkouts back, keeping the earliest start
+	// at a till of everybody who got there later. Customers that got there in the same
+	// second are not counted as overtaking each other.
+	sort.SliceStable(served, func(i, j int) bool {
+		return served[i].reachedCheckouts < served[j].reachedCheckouts
+	})
+	overtaken := 0
+	earliestLaterStart := int64(math.MaxInt64)
+	for end := len(served); end > 0; {
+		start := end - 1
+		for start > 0 && served[start-1].reachedCheckouts == served[end-1].reachedCheckouts {
+			start--
+		}
+		for _, eCustomer := range served[start:end] {
+			if earliestLaterStart < eCustomer.checkoutTimeStart {
+				overtaken++
+			}
+		}
+		for _, eCustomer := range served[start:end] {
+			if eCustomer.checkoutTimeStart < earliestLaterStart {
+				earliestLaterStart = eCustomer.checkoutTimeStart
+			}
+		}
+		end = start
+	}
+	result.overtaken = 100 * float64(overtaken) / float64(len(served))
+
+	return result
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// We only need the block style part of YAML for scenario files (nested maps, lists and
+// scalars), so rather than pulling in a dependency we read that subset here. The result
+// is made of map[string]interface{}, []interface{} and string values, which can then go
+// through encoding/json like a JSON scenario file does.
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+func parseYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, " \r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text = stripYAMLComment(text)
+		if text == "" || text == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	value, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].number)
+	}
+	return value, nil
+}
+
+func parseYAMLBlock(lines []yamlLine, i int, indent int) (interface{}, int, error) {
+	if isYAMLListItem(lines[i].text) {
+		return parseYAMLList(lines, i, indent)
+	}
+	return parseYAMLMap(lines, i, indent)
+}
+
+func parseYAMLMap(lines []yamlLine, i int, indent int) (interface{}, int, error) {
+	values := map[string]interface{}{}
+
+	for i < len(lines) && lines[i].indent == indent {
+		if isYAMLListItem(lines[i].text) {
+			return nil, i, fmt.Errorf("line %d: expected \"key: value\" but found a list item", lines[i].number)
+		}
+		key, rest, ok := splitYAMLKey(lines[i].text)
+		if !ok {
+			return nil, i, fmt.Errorf("line %d: expected \"key: value\"", lines[i].number)
+		}
+		if _, exists := values[key]; exists {
+			return nil, i, fmt.Errorf("line %d: %q is defined twice", lines[i].number, key)
+		}
+		i++
+
+		var value interface{} = ""
+		var err error
+		if rest != "" {
+			value = yamlScalar(rest)
+		} else if i < len(lines) && lines[i].indent > indent {
+			value, i, err = parseYAMLBlock(lines, i, lines[i].indent)
+		} else if i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text) {
+			// YAML lets a list sit at the same indentation as its key.
+			value, i, err = parseYAMLList(lines, i, indent)
+		}
+		if err != nil {
+			return nil, i, err
+		}
+		values[key] = value
+	}
+
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, i, fmt.Errorf("line %d: unexpected indentation", lines[i].number)
+	}
+	return values, i, nil
+}
+
+func parseYAMLList(lines []yamlLine, i int, indent int) (interface{}, int, error) {
+	values := []interface{}{}
+
+	for i < len(lines) && lines[i].indent == indent && isYAMLListItem(lines[i].text) {
+		text := lines[i].text
+		rest := strings.TrimLeft(text[1:], " ")
+
+		var value interface{} = ""
+		var err error
+		if rest == "" {
+			i++
+			if i < len(lines) && lines[i].indent > indent {
+				value, i, err = parseYAMLBlock(lines, i, lines[i].indent)
+			}
+		} else if _, _, isMap := splitYAMLKey(rest); isMap || isYAMLListItem(rest) {
+			// "- key: value" starts a map whose keys line up with "key".
+			itemIndent := indent + len(text) - len(rest)
+			lines[i] = yamlLine{number: lines[i].number, indent: itemIndent, text: rest}
+			value, i, err = parseYAMLBlock(lines, i, itemIndent)
+		} else {
+			value = yamlScalar(rest)
+			i++
+		}
+		if err != nil {
+			return nil, i, err
+		}
+		values = append(values, value)
+	}
+
+	return values, i, nil
+}
+
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey finds the "key: value" separator, ignoring colons inside quotes.
+func splitYAMLKey(text string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote != 0:
+			if text[i] == quote {
+				quote = 0
+			}
+		case text[i] == '"' || text[i] == '\'':
+			quote = text[i]
+		case text[i] == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key := yamlScalar(strings.TrimSpace(text[:i]))
+			if key == "" {
+				return "", "", false
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment drops everything after a # that is not inside quotes.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote != 0:
+			if text[i] == quote {
+				quote = 0
+			}
+		case text[i] == '"' || text[i] == '\'':
+			quote = text[i]
+		case text[i] == '#' && (i == 0 || text[i-1] == ' '):
+			return strings.TrimRight(text[:i], " ")
+		}
+	}
+	return text
+}
+
+func yamlScalar(text string) string {
+	if len(text) >= 2 && (text[0] == '"' || text[0] == '\'') && text[len(text)-1] == text[0] {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
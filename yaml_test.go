@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want interface{}
+	}{
+		{"empty", "", map[string]interface{}{}},
+		{"comments and document marker", "---\n# only a comment\n", map[string]interface{}{}},
+		{"scalars", "a: 1\nb: two words\nc:\n", map[string]interface{}{"a": "1", "b": "two words", "c": ""}},
+		{"quotes", "\"9\": q\nroster: 'ANNA@9-15 # not a comment'\nd: x # comment\n",
+			map[string]interface{}{"9": "q", "roster": "ANNA@9-15 # not a comment", "d": "x"}},
+		{"colon in a quoted key", "\"a:b\": c\n", map[string]interface{}{"a:b": "c"}},
+		{"nested map", "a:\n  b:\n    c: 1\n  d: 2\n",
+			map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "1"}, "d": "2"}}},
+		{"list", "a:\n  - 1\n  - 2\n", map[string]interface{}{"a": []interface{}{"1", "2"}}},
+		{"list at the key's indentation", "a:\n- 1\n- 2\nb: 3\n",
+			map[string]interface{}{"a": []interface{}{"1", "2"}, "b": "3"}},
+		{"list of maps", "a:\n  - x: 1\n    y: 2\n  - x: 3\n",
+			map[string]interface{}{"a": []interface{}{
+				map[string]interface{}{"x": "1", "y": "2"},
+				map[string]interface{}{"x": "3"},
+			}}},
+		{"empty list item", "a:\n  -\n  - 2\n", map[string]interface{}{"a": []interface{}{"", "2"}}},
+		{"windows line endings", "a: 1\r\nb: 2\r\n", map[string]interface{}{"a": "1", "b": "2"}},
+	}
+	for _, test := range tests {
+		got, err := parseYAML([]byte(test.text))
+		if err != nil {
+			t.Errorf("%s: parseYAML(%q) failed: %v", test.name, test.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseYAML(%q) = %#v, want %#v", test.name, test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "line 2: tabs"},
+		{"no key", "a: 1\njust text\n", "line 2: expected \"key: value\""},
+		{"duplicate key", "a: 1\na: 2\n", "line 2: \"a\" is defined twice"},
+		{"list item in a map", "a: 1\n- 2\n", "line 2: expected \"key: value\" but found a list item"},
+		{"indented after a scalar", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"indented after a list", "- 1\n  - 2\n", "line 2: unexpected indentation"},
+	}
+	for _, test := range tests {
+		_, err := parseYAML([]byte(test.text))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: parseYAML(%q) error = %v, want %q", test.name, test.text, err, test.want)
+		}
+	}
+}